@@ -2,16 +2,22 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/zkportal/oracle-verification-backend/api/handlers"
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/challenge"
 	"github.com/zkportal/oracle-verification-backend/config"
-
-	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/liveCheck"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
 
 	"github.com/rs/cors"
 )
 
-func CreateApi(aleoWrapper aleo_wrapper.Wrapper, conf *config.Configuration) http.Handler {
+// CreateApi creates the API handler. The refresher is nil if the live contract is not checked
+func CreateApi(pool *sessionPool.Pool, conf *config.Configuration, client *contract.Client, measurements *attestation.MeasurementRegistry, refresher *liveCheck.Refresher, historyStore history.Store) http.Handler {
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{http.MethodPost},
@@ -23,11 +29,16 @@ func CreateApi(aleoWrapper aleo_wrapper.Wrapper, conf *config.Configuration) htt
 
 	mux := http.NewServeMux()
 
-	targetPcrs := [3]string{conf.PcrValuesTarget[0], conf.PcrValuesTarget[1], conf.PcrValuesTarget[2]}
+	challenger := challenge.NewChallenger(challenge.NewMemoryStore(conf.Challenges.MaxOutstanding, conf.Challenges.MaxOutstandingPerClient), time.Duration(conf.Challenges.TtlSeconds)*time.Second)
 
-	mux.Handle("/info", addMiddleware(handlers.CreateInfoHandler(conf.UniqueIdTarget, targetPcrs, conf.LiveCheck.ContractName)))
-	mux.Handle("/verify", addMiddleware(handlers.CreateVerifyHandler(aleoWrapper, conf.UniqueIdTarget, targetPcrs)))
-	mux.Handle("/decode", addMiddleware(handlers.CreateDecodeHandler(aleoWrapper)))
+	mux.Handle("/info", addMiddleware(handlers.CreateInfoHandler(measurements, conf.PriceFeeds, conf.LiveCheck.ContractName, refresher, client)))
+	mux.Handle("/verify", addMiddleware(handlers.CreateVerifyHandler(pool, measurements, &conf.SgxPolicy, &conf.Freshness, challenger, conf.Challenges.Require, conf.VerifyWorkers, conf.VerifyMaxReports, conf.VerifyBudget(), historyStore)))
+	mux.Handle("/verify/transaction", addMiddleware(handlers.CreateVerifyTransactionHandler(pool, measurements, &conf.SgxPolicy, client, conf.LiveCheck.ContractName, conf.LiveCheck.UpdateFunctions, conf.NodeApiTimeout(), historyStore)))
+	mux.Handle("/nonce", addMiddleware(handlers.CreateNonceHandler(challenger)))
+	mux.Handle("/decode", addMiddleware(handlers.CreateDecodeHandler(pool, measurements, &conf.SgxPolicy)))
+	mux.Handle("/decode/batch", addMiddleware(handlers.CreateDecodeBatchHandler(pool, measurements, &conf.SgxPolicy, conf.DecodeBatchMaxReports(), conf.VerifyBudget())))
+	mux.Handle("/encode", addMiddleware(handlers.CreateEncodeHandler(pool)))
+	mux.Handle("/history", addMiddleware(handlers.CreateHistoryHandler(historyStore)))
 
 	return mux
 }
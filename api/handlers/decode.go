@@ -2,33 +2,85 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/leo"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+	"github.com/zkportal/oracle-verification-backend/u128"
 
 	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
 
-	"github.com/zkportal/oracle-verification-backend/attestation"
+// Formats of the proof data in a decode request
+const (
+	// ReportData Leo struct string
+	DECODE_FORMAT_LEO = "leo"
+	// base64-encoded proof data bytes
+	DECODE_FORMAT_BASE64 = "base64"
+	// hex-encoded proof data bytes
+	DECODE_FORMAT_HEX = "hex"
+	// proof data as u128 chunks, in the same order as in the ReportData Leo struct
+	DECODE_FORMAT_U128 = "u128"
+)
+
+var (
+	ErrUnsupportedDecodeFormat = errors.New("unsupported proof data format")
+	ErrInvalidUserData         = errors.New("invalid userData")
 )
 
 type DecodeProofDataRequest struct {
-	UserData string `json:"userData"`
+	// one of the DECODE_FORMAT_ values, defaults to "leo"
+	Format   string `json:"format,omitempty"`
+	UserData string `json:"userData,omitempty"`
+	// u128 values with or without the "u128" suffix, used instead of UserData if Format is "u128"
+	Chunks []string `json:"chunks,omitempty"`
+	// if true, the response has the position and raw bytes of every field of the proof data
+	IncludeLayout bool `json:"includeLayout,omitempty"`
+	// optional report to match against the hash of the proof data
+	Report *DecodeReport `json:"report,omitempty"`
+}
+
+// TEE report to match against the decoded proof data
+type DecodeReport struct {
+	ReportType string `json:"reportType"`
+	// base64-encoded report
+	AttestationReport string `json:"attestationReport"`
+	// nonce of a Nitro report, optional
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Poseidon8 hash of the proof data, which the enclave puts in the first 16 bytes of the report's userData
+type ProofDataHash struct {
+	Hex    string `json:"hex"`
+	Base64 string `json:"base64"`
+	U128   string `json:"u128"`
 }
 
 type DecodeProofDataResponse struct {
-	DecodedData  *attestation.DecodedProofData `json:"decodedData,omitempty"`
-	Success      bool                          `json:"success"`
-	ErrorMessage string                        `json:"errorMessage,omitempty"`
+	DecodedData *attestation.DecodedProofData `json:"decodedData"`
+	Hash        *ProofDataHash                `json:"hash"`
+	// set only if the request has a report, true if the report's userData starts with the hash
+	ReportMatches *bool                     `json:"reportMatches,omitempty"`
+	Layout        []attestation.FieldLayout `json:"layout,omitempty"`
+	Success       bool                      `json:"success"`
 }
 
-func respondDecode(ctx context.Context, w http.ResponseWriter, decodedData *attestation.DecodedProofData, err error) {
+func respondDecode(ctx context.Context, w http.ResponseWriter, result *DecodeProofDataResult) {
 	r := &DecodeProofDataResponse{
-		DecodedData: decodedData,
-		Success:     decodedData != nil,
-	}
-
-	if err != nil {
-		r.ErrorMessage = err.Error()
+		DecodedData:   result.DecodedData,
+		Hash:          result.Hash,
+		ReportMatches: result.ReportMatches,
+		Layout:        result.Layout,
+		Success:       true,
 	}
 
 	log := GetContextLogger(ctx)
@@ -36,7 +88,7 @@ func respondDecode(ctx context.Context, w http.ResponseWriter, decodedData *atte
 	msg, err := json.Marshal(r)
 	if err != nil {
 		log.Println("failed to marshal response:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(ctx, w, NewApiError(ErrorInternal, nil))
 		return
 	}
 
@@ -44,62 +96,171 @@ func respondDecode(ctx context.Context, w http.ResponseWriter, decodedData *atte
 	w.Write(msg)
 }
 
-func CreateDecodeHandler(aleo aleo_wrapper.Wrapper) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		if req.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
+// returns the proof data bytes from a decode request in any of the supported formats
+func getProofData(request *DecodeProofDataRequest) ([]byte, error) {
+	var buf []byte
+	var err error
 
-		if req.Header.Get("Content-Type") != "application/json" {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+	switch request.Format {
+	case "", DECODE_FORMAT_LEO:
+		buf, err = leo.ParseMessage(request.UserData)
+	case DECODE_FORMAT_BASE64:
+		buf, err = base64.StdEncoding.DecodeString(request.UserData)
+	case DECODE_FORMAT_HEX:
+		buf, err = hex.DecodeString(strings.TrimPrefix(request.UserData, "0x"))
+	case DECODE_FORMAT_U128:
+		buf = make([]byte, 0, len(request.Chunks)*16)
+		for idx, chunk := range request.Chunks {
+			chunkBytes, chunkErr := u128.ParseU128(chunk)
+			if chunkErr != nil {
+				err = fmt.Errorf("chunk %d: %w", idx, chunkErr)
+				break
+			}
+			buf = append(buf, chunkBytes...)
 		}
+	default:
+		return nil, ErrUnsupportedDecodeFormat
+	}
 
-		log := GetContextLogger(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUserData, err)
+	}
 
-		body, err := io.ReadAll(req.Body)
-		defer req.Body.Close()
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+	return buf, nil
+}
 
-		request := new(DecodeProofDataRequest)
-		err = json.Unmarshal(body, request)
-		if err != nil {
-			log.Println("error reading request", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+// checks that a decode request has the input for its format
+func validateDecodeRequest(request *DecodeProofDataRequest) *ApiError {
+	if request.Format == DECODE_FORMAT_U128 && len(request.Chunks) == 0 {
+		return NewApiError(ErrorInvalidRequest, "\"chunks\" must not be empty")
+	}
 
-		if request.UserData == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+	if request.Format != DECODE_FORMAT_U128 && request.UserData == "" {
+		return NewApiError(ErrorInvalidRequest, "\"userData\" must not be empty")
+	}
 
-		aleoSession, err := aleo.NewSession()
-		if err != nil {
-			log.Println("error creating new aleo session:", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		defer aleoSession.Close()
+	if request.Report != nil && (request.Report.ReportType == "" || request.Report.AttestationReport == "") {
+		return NewApiError(ErrorInvalidRequest, "\"report\" must have \"reportType\" and \"attestationReport\"")
+	}
 
-		recoveredMessage, err := aleoSession.RecoverMessage([]byte(request.UserData))
-		if err != nil {
-			log.Println("error recovering formatted message:", err)
-			respondDecode(req.Context(), w, nil, err)
-			return
+	return nil
+}
+
+type decodeHandler struct {
+	pool         *sessionPool.Pool
+	measurements *attestation.MeasurementRegistry
+	sgxPolicy    *sgx.Policy
+}
+
+// verifies the report attached to a decode request and checks whether its userData starts with the proof data hash
+func (dh *decodeHandler) matchReport(report *DecodeReport, hash []byte) (bool, error) {
+	reportBytes, err := base64.StdEncoding.DecodeString(report.AttestationReport)
+	if err != nil {
+		return false, NewApiError(VerifyErrorInvalidReportEncoding, err.Error())
+	}
+
+	verifiedReport, err := attestation.VerifyReport(report.ReportType, reportBytes, report.Nonce, dh.measurements.Sets(), dh.sgxPolicy, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return attestation.MatchUserData(hash, verifiedReport.UserData) == nil, nil
+}
+
+// decodes the proof data from a validated decode request, hashes it, and matches it against the attached report
+func (dh *decodeHandler) decode(ctx context.Context, aleoSession aleo_wrapper.Session, request *DecodeProofDataRequest) (*DecodeProofDataResult, *ApiError) {
+	log := GetContextLogger(ctx)
+
+	proofData, err := getProofData(request)
+	if err != nil {
+		log.Println("error reading proof data:", err)
+		return nil, apiErrorFromError(err)
+	}
+
+	result := new(DecodeProofDataResult)
+	if request.IncludeLayout {
+		result.Layout = attestation.ProofDataLayout(proofData)
+	}
+
+	result.DecodedData, err = attestation.DecodeProofData(proofData)
+	if err != nil {
+		log.Println("error decoding proof data:", err)
+
+		apiErr := apiErrorFromError(err)
+		// the layout helps to find out why the proof data cannot be decoded
+		if details, ok := apiErr.Details.(map[string]interface{}); ok && result.Layout != nil {
+			details["layout"] = result.Layout
 		}
 
-		decodedData, err := attestation.DecodeProofData(recoveredMessage)
+		return nil, apiErr
+	}
+
+	hashed, err := attestation.HashProofData(aleoSession, proofData)
+	if err != nil {
+		log.Println("error hashing proof data:", err)
+		return nil, apiErrorFromError(err)
+	}
+
+	result.Hash = &ProofDataHash{
+		Hex:    hex.EncodeToString(hashed.Hash),
+		Base64: base64.StdEncoding.EncodeToString(hashed.Hash),
+		U128:   hashed.HashU128,
+	}
+
+	if request.Report != nil {
+		matches, err := dh.matchReport(request.Report, hashed.Hash)
 		if err != nil {
-			log.Println("error decoding proof data:", err)
-			respondDecode(req.Context(), w, nil, err)
-			return
+			log.Printf("error verifying %s report: %s\n", request.Report.ReportType, err)
+
+			var apiErr *ApiError
+			if errors.As(err, &apiErr) {
+				return nil, apiErr
+			}
+			return nil, apiErrorFromError(err)
 		}
 
-		respondDecode(req.Context(), w, decodedData, nil)
+		result.ReportMatches = &matches
+	}
+
+	return result, nil
+}
+
+func (dh *decodeHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	request := new(DecodeProofDataRequest)
+	if !readJsonRequest(w, req, request) {
+		return
+	}
+
+	log := GetContextLogger(req.Context())
+
+	if apiErr := validateDecodeRequest(request); apiErr != nil {
+		respondError(req.Context(), w, apiErr)
+		return
+	}
+
+	aleoSession, err := dh.pool.Get(req.Context())
+	if err != nil {
+		log.Println("error getting aleo session:", err)
+		respondError(req.Context(), w, NewApiError(ErrorServiceUnavailable, nil))
+		return
+	}
+	defer dh.pool.Put(aleoSession)
+
+	result, apiErr := dh.decode(req.Context(), aleoSession, request)
+	if apiErr != nil {
+		respondError(req.Context(), w, apiErr)
+		return
+	}
+
+	respondDecode(req.Context(), w, result)
+}
+
+// CreateDecodeHandler creates a handler that decodes proof data. Reports attached to the requests are verified
+// with the provided measurements and SGX policy before their userData is compared with the proof data hash.
+func CreateDecodeHandler(pool *sessionPool.Pool, measurements *attestation.MeasurementRegistry, sgxPolicy *sgx.Policy) http.Handler {
+	return &decodeHandler{
+		pool:         pool,
+		measurements: measurements,
+		sgxPolicy:    sgxPolicy,
 	}
 }
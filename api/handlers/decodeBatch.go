@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+)
+
+const (
+	// maximum number of items in one batch decode request
+	maxDecodeBatchSize = 1000
+	// upper bound of the number of items with a report in one batch decode request. Reports are verified one after another,
+	// and verification is much slower than decoding
+	maxDecodeBatchReports = 100
+)
+
+type DecodeProofDataBatchRequest struct {
+	Items []DecodeProofDataRequest `json:"items"`
+}
+
+// Decoding result for a single item in the batch
+type DecodeProofDataResult struct {
+	Index       int                           `json:"index"`
+	Success     bool                          `json:"success"`
+	DecodedData *attestation.DecodedProofData `json:"decodedData,omitempty"`
+	Hash        *ProofDataHash                `json:"hash,omitempty"`
+	// set only if the item has a report, true if the report's userData starts with the hash
+	ReportMatches *bool                     `json:"reportMatches,omitempty"`
+	Layout        []attestation.FieldLayout `json:"layout,omitempty"`
+	Error         *ApiError                 `json:"error,omitempty"`
+}
+
+type DecodeProofDataBatchResponse struct {
+	Results []DecodeProofDataResult `json:"results"`
+	// number of successfully decoded items
+	Decoded int  `json:"decoded"`
+	Success bool `json:"success"`
+}
+
+func respondDecodeBatch(ctx context.Context, w http.ResponseWriter, results []DecodeProofDataResult) {
+	r := &DecodeProofDataBatchResponse{
+		Results: results,
+		Success: true,
+	}
+
+	for _, result := range results {
+		if result.Success {
+			r.Decoded++
+		}
+	}
+
+	log := GetContextLogger(ctx)
+
+	msg, err := json.Marshal(r)
+	if err != nil {
+		log.Println("failed to marshal response:", err)
+		respondError(ctx, w, NewApiError(ErrorInternal, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(msg)
+}
+
+type decodeBatchHandler struct {
+	decodeHandler
+	// maximum number of items with a report, the reports must be verified before the write timeout
+	maxReports int
+	// how long a request waits for an Aleo session
+	sessionWait time.Duration
+}
+
+func (dh *decodeBatchHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	request := new(DecodeProofDataBatchRequest)
+	if !readJsonRequest(w, req, request) {
+		return
+	}
+
+	log := GetContextLogger(req.Context())
+
+	if len(request.Items) == 0 {
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, "\"items\" must not be empty"))
+		return
+	}
+
+	if len(request.Items) > maxDecodeBatchSize {
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, fmt.Sprintf("\"items\" must not have more than %d items", maxDecodeBatchSize)))
+		return
+	}
+
+	numReports := 0
+	for idx := range request.Items {
+		if request.Items[idx].Report != nil {
+			numReports++
+		}
+	}
+
+	if numReports > dh.maxReports {
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, fmt.Sprintf("\"items\" must not have more than %d reports", dh.maxReports)))
+		return
+	}
+
+	sessionCtx := req.Context()
+	if dh.sessionWait > 0 {
+		var cancel context.CancelFunc
+		sessionCtx, cancel = context.WithTimeout(sessionCtx, dh.sessionWait)
+		defer cancel()
+	}
+
+	aleoSession, err := dh.pool.Get(sessionCtx)
+	if err != nil {
+		log.Println("error getting aleo session:", err)
+		respondError(req.Context(), w, NewApiError(ErrorServiceUnavailable, nil))
+		return
+	}
+	defer dh.pool.Put(aleoSession)
+
+	results := make([]DecodeProofDataResult, len(request.Items))
+	for idx := range request.Items {
+		item := &request.Items[idx]
+		results[idx].Index = idx
+
+		if apiErr := validateDecodeRequest(item); apiErr != nil {
+			results[idx].Error = apiErr
+			continue
+		}
+
+		result, apiErr := dh.decode(req.Context(), aleoSession, item)
+		if apiErr != nil {
+			results[idx].Error = apiErr
+			continue
+		}
+
+		result.Index = idx
+		result.Success = true
+		results[idx] = *result
+	}
+
+	respondDecodeBatch(req.Context(), w, results)
+}
+
+// CreateDecodeBatchHandler creates a handler that decodes many proof data items with one Aleo session.
+// Every item gets its own result, failing items don't fail the request. maxReports is capped at maxDecodeBatchReports
+func CreateDecodeBatchHandler(pool *sessionPool.Pool, measurements *attestation.MeasurementRegistry, sgxPolicy *sgx.Policy, maxReports int, sessionWait time.Duration) http.Handler {
+	if maxReports <= 0 || maxReports > maxDecodeBatchReports {
+		maxReports = maxDecodeBatchReports
+	}
+
+	return &decodeBatchHandler{
+		decodeHandler: decodeHandler{
+			pool:         pool,
+			measurements: measurements,
+			sgxPolicy:    sgxPolicy,
+		},
+		maxReports:  maxReports,
+		sessionWait: sessionWait,
+	}
+}
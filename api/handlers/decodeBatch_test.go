@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+func TestDecodeBatchHandler(t *testing.T) {
+	pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	proofData, err := attestation.PrepareProofData(http.StatusOK, "42", 1701851063, &attestation.AttestationRequest{
+		Url:            "localhost:8080/resource",
+		RequestMethod:  http.MethodGet,
+		Selector:       "data.value",
+		ResponseFormat: "json",
+		EncodingOptions: encoding.EncodingOptions{
+			Value: encoding.ENCODING_OPTION_INT,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const maxReports = 10
+	handler := CreateDecodeBatchHandler(pool, nil, nil, maxReports, 10*time.Millisecond)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantResults []string
+	}{
+		{
+			name:       "no items",
+			body:       `{"items": []}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "mixed results",
+			body: `{"items": [
+				{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `"},
+				{"format": "hex", "userData": "not hex"},
+				{"format": "hex"},
+				{"format": "hex", "userData": "` + hex.EncodeToString(proofData[:64]) + `"},
+				{"format": "yaml", "userData": "abc"},
+				{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `", "report": {"reportType": "sgx"}},
+				{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `", "report": {"reportType": "sgx", "attestationReport": "not base64"}},
+				{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `", "report": {"reportType": "tdx", "attestationReport": "AAAA"}}
+			]}`,
+			wantStatus: http.StatusOK,
+			wantResults: []string{
+				"",
+				ErrorInvalidUserData,
+				ErrorInvalidRequest,
+				ErrorProofDataOutOfBounds,
+				ErrorUnsupportedDecodeFormat,
+				ErrorInvalidRequest,
+				VerifyErrorInvalidReportEncoding,
+				VerifyErrorUnsupportedReportType,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/decode/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			resp := new(DecodeProofDataBatchResponse)
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) != len(tt.wantResults) {
+				t.Fatalf("got %d results, want %d", len(resp.Results), len(tt.wantResults))
+			}
+
+			for idx, result := range resp.Results {
+				if result.Index != idx {
+					t.Errorf("result %d has index %d", idx, result.Index)
+				}
+
+				wantCode := tt.wantResults[idx]
+				if wantCode == "" {
+					if !result.Success || result.DecodedData == nil || result.DecodedData.AttestationData != "42" {
+						t.Errorf("result %d = %+v, want successfully decoded data", idx, result)
+					}
+					if result.Hash == nil || result.Hash.Hex != hex.EncodeToString(proofData[:16]) {
+						t.Errorf("result %d hash = %+v, want the first 16 bytes of the proof data", idx, result.Hash)
+					}
+					if result.ReportMatches != nil {
+						t.Errorf("result %d has a report match without a report", idx)
+					}
+					continue
+				}
+
+				if result.Success || result.Error == nil || result.Error.Code != wantCode {
+					t.Errorf("result %d = %+v, want error %s", idx, result, wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeBatchHandlerLimits(t *testing.T) {
+	pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	proofData, err := attestation.PrepareProofData(http.StatusOK, "42", 1701851063, &attestation.AttestationRequest{
+		Url:            "localhost:8080/resource",
+		RequestMethod:  http.MethodGet,
+		Selector:       "data.value",
+		ResponseFormat: "json",
+		EncodingOptions: encoding.EncodingOptions{
+			Value: encoding.ENCODING_OPTION_INT,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := `{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `"}`
+	itemWithReport := `{"format": "hex", "userData": "` + hex.EncodeToString(proofData) + `", "report": {"reportType": "sgx", "attestationReport": "not base64"}}`
+
+	makeBody := func(items, reports int) string {
+		body := make([]string, 0, items)
+		for i := 0; i < items; i++ {
+			if i < reports {
+				body = append(body, itemWithReport)
+			} else {
+				body = append(body, item)
+			}
+		}
+		return `{"items": [` + strings.Join(body, ",") + `]}`
+	}
+
+	const maxReports = 10
+	handler := CreateDecodeBatchHandler(pool, nil, nil, maxReports, 10*time.Millisecond)
+
+	tests := []struct {
+		name        string
+		items       int
+		reports     int
+		wantStatus  int
+		wantDecoded int
+	}{
+		{
+			name:        "at the limits",
+			items:       maxDecodeBatchSize,
+			reports:     maxReports,
+			wantStatus:  http.StatusOK,
+			wantDecoded: maxDecodeBatchSize - maxReports,
+		},
+		{
+			name:       "too many items",
+			items:      maxDecodeBatchSize + 1,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many reports",
+			items:      maxReports + 1,
+			reports:    maxReports + 1,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/decode/batch", strings.NewReader(makeBody(tt.items, tt.reports)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			resp := new(DecodeProofDataBatchResponse)
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) != tt.items || resp.Decoded != tt.wantDecoded {
+				t.Errorf("got %d results with %d decoded, want %d with %d decoded", len(resp.Results), resp.Decoded, tt.items, tt.wantDecoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+)
+
+type EncodeProofDataResponse struct {
+	EncodedData *attestation.EncodedProofData `json:"encodedData"`
+	Success     bool                          `json:"success"`
+}
+
+func respondEncode(ctx context.Context, w http.ResponseWriter, encodedData *attestation.EncodedProofData) {
+	r := &EncodeProofDataResponse{
+		EncodedData: encodedData,
+		Success:     true,
+	}
+
+	log := GetContextLogger(ctx)
+
+	msg, err := json.Marshal(r)
+	if err != nil {
+		log.Println("failed to marshal response:", err)
+		respondError(ctx, w, NewApiError(ErrorInternal, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(msg)
+}
+
+// CreateEncodeHandler creates a handler that computes the proof data and hash that an enclave
+// commits to for an attestation response
+func CreateEncodeHandler(pool *sessionPool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		request := new(attestation.AttestationResponse)
+		if !readJsonRequest(w, req, request) {
+			return
+		}
+
+		log := GetContextLogger(req.Context())
+
+		aleoSession, err := pool.Get(req.Context())
+		if err != nil {
+			log.Println("error getting aleo session:", err)
+			respondError(req.Context(), w, NewApiError(ErrorServiceUnavailable, nil))
+			return
+		}
+		defer pool.Put(aleoSession)
+
+		encodedData, err := attestation.EncodeProofData(aleoSession, request)
+		if err != nil {
+			log.Println("error encoding proof data:", err)
+			respondError(req.Context(), w, apiErrorFromError(err))
+			return
+		}
+
+		respondEncode(req.Context(), w, encodedData)
+	}
+}
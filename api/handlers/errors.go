@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/nitro"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/challenge"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/leo"
+)
+
+// Request error codes
+const (
+	ErrorMethodNotAllowed        = "METHOD_NOT_ALLOWED"
+	ErrorUnsupportedContentType  = "UNSUPPORTED_CONTENT_TYPE"
+	ErrorInvalidRequestBody      = "INVALID_REQUEST_BODY"
+	ErrorInvalidRequest          = "INVALID_REQUEST"
+	ErrorInternal                = "INTERNAL_ERROR"
+	ErrorServiceUnavailable      = "SERVICE_UNAVAILABLE"
+	ErrorUnsupportedDecodeFormat = "UNSUPPORTED_DECODE_FORMAT"
+	ErrorInvalidUserData         = "INVALID_USERDATA"
+	ErrorProofDataTooShort       = "PROOF_DATA_TOO_SHORT"
+	ErrorProofDataOutOfBounds    = "PROOF_DATA_FIELD_OUT_OF_BOUNDS"
+	ErrorProofDataUnexpectedLen  = "PROOF_DATA_UNEXPECTED_FIELD_LENGTH"
+	ErrorProofDataMalformedField = "PROOF_DATA_MALFORMED_FIELD"
+	ErrorTransactionNotFound     = "TRANSACTION_NOT_FOUND"
+	ErrorNoOracleTransition      = "NO_ORACLE_TRANSITION"
+	ErrorInvalidTransitionInput  = "INVALID_TRANSITION_INPUT"
+	ErrorNodeApi                 = "NODE_API_ERROR"
+	ErrorTooManyNonces           = "TOO_MANY_NONCES"
+	ErrorTooManyClientNonces     = "TOO_MANY_CLIENT_NONCES"
+	ErrorInvalidLeoValue         = "INVALID_LEO_VALUE"
+)
+
+// Report verification error codes
+const (
+	VerifyErrorInvalidReportEncoding = "INVALID_REPORT_ENCODING"
+	VerifyErrorUnsupportedReportType = "UNSUPPORTED_REPORT_TYPE"
+	VerifyErrorUniqueIdMismatch      = "UNIQUE_ID_MISMATCH"
+	VerifyErrorSignerMismatch        = "SIGNER_MISMATCH"
+	VerifyErrorDebugEnclave          = "DEBUG_ENCLAVE"
+	VerifyErrorSecurityVersion       = "SECURITY_VERSION_TOO_LOW"
+	VerifyErrorTcbStatus             = "TCB_STATUS_REJECTED"
+	VerifyErrorPcrMismatch           = "PCR_MISMATCH"
+	VerifyErrorNonceMismatch         = "NONCE_MISMATCH"
+	VerifyErrorUserDataLength        = "UNEXPECTED_USERDATA_LENGTH"
+	VerifyErrorReportInvalid         = "REPORT_INVALID"
+	VerifyErrorProofDataPreparation  = "PROOF_DATA_PREPARATION_FAILED"
+	VerifyErrorProofDataFormatting   = "PROOF_DATA_FORMATTING_FAILED"
+	VerifyErrorProofDataHashing      = "PROOF_DATA_HASHING_FAILED"
+	VerifyErrorUserDataHashMismatch  = "USERDATA_HASH_MISMATCH"
+	VerifyErrorAttestationTooOld     = "ATTESTATION_TOO_OLD"
+	VerifyErrorDocumentTooOld        = "NITRO_DOCUMENT_TOO_OLD"
+	VerifyErrorTimestampInFuture     = "TIMESTAMP_IN_FUTURE"
+	VerifyErrorNonceNotIssued        = "NONCE_NOT_ISSUED"
+	VerifyErrorNonceExpired          = "NONCE_EXPIRED"
+	VerifyErrorNonceReused           = "NONCE_REUSED"
+	VerifyErrorGroupReportInvalid    = "GROUP_REPORT_INVALID"
+	VerifyErrorSingleTeeGroup        = "SINGLE_TEE_GROUP"
+	VerifyErrorGroupDataMismatch     = "GROUP_ATTESTATION_DATA_MISMATCH"
+	VerifyErrorGroupTimestamp        = "GROUP_TIMESTAMP_MISMATCH"
+	VerifyErrorGroupUserData         = "GROUP_USERDATA_MISMATCH"
+)
+
+type errorCatalogueEntry struct {
+	status  int
+	message string
+}
+
+// HTTP status and message for every error code. Codes and statuses are stable, messages may change
+var errorCatalogue = map[string]errorCatalogueEntry{
+	ErrorMethodNotAllowed:        {http.StatusMethodNotAllowed, "method not allowed"},
+	ErrorUnsupportedContentType:  {http.StatusUnsupportedMediaType, "request content type must be application/json"},
+	ErrorInvalidRequestBody:      {http.StatusBadRequest, "request body is not valid JSON"},
+	ErrorInvalidRequest:          {http.StatusBadRequest, "request is missing a required field or has an invalid value"},
+	ErrorInternal:                {http.StatusInternalServerError, "internal error"},
+	ErrorServiceUnavailable:      {http.StatusServiceUnavailable, "service is temporarily unavailable"},
+	ErrorUnsupportedDecodeFormat: {http.StatusBadRequest, "unsupported proof data format"},
+	ErrorInvalidUserData:         {http.StatusUnprocessableEntity, "userData cannot be read in the requested format"},
+	ErrorProofDataTooShort:       {http.StatusUnprocessableEntity, "proof data is too short"},
+	ErrorProofDataOutOfBounds:    {http.StatusUnprocessableEntity, "proof data field extends past the end of the data"},
+	ErrorProofDataUnexpectedLen:  {http.StatusUnprocessableEntity, "proof data meta header has an unexpected field length"},
+	ErrorProofDataMalformedField: {http.StatusUnprocessableEntity, "proof data field is malformed"},
+	ErrorTransactionNotFound:     {http.StatusNotFound, "transaction not found"},
+	ErrorNoOracleTransition:      {http.StatusUnprocessableEntity, "transaction doesn't update the oracle contract"},
+	ErrorInvalidTransitionInput:  {http.StatusUnprocessableEntity, "oracle update transition has unexpected inputs"},
+	ErrorNodeApi:                 {http.StatusBadGateway, "Aleo node API request failed"},
+	ErrorTooManyNonces:           {http.StatusServiceUnavailable, "too many outstanding nonces, try again later"},
+	ErrorTooManyClientNonces:     {http.StatusTooManyRequests, "too many outstanding nonces for this client, try again later"},
+	ErrorInvalidLeoValue:         {http.StatusUnprocessableEntity, "value is not a valid Leo value"},
+
+	VerifyErrorInvalidReportEncoding: {http.StatusUnprocessableEntity, "report is not valid base64"},
+	VerifyErrorUnsupportedReportType: {http.StatusUnprocessableEntity, "unsupported report type"},
+	VerifyErrorUniqueIdMismatch:      {http.StatusUnprocessableEntity, "SGX unique ID doesn't match any accepted measurements"},
+	VerifyErrorSignerMismatch:        {http.StatusUnprocessableEntity, "SGX signer ID or product ID doesn't match the policy"},
+	VerifyErrorDebugEnclave:          {http.StatusUnprocessableEntity, "report is from a debug enclave"},
+	VerifyErrorSecurityVersion:       {http.StatusUnprocessableEntity, "enclave security version is too low"},
+	VerifyErrorTcbStatus:             {http.StatusUnprocessableEntity, "TCB status is not accepted"},
+	VerifyErrorPcrMismatch:           {http.StatusUnprocessableEntity, "Nitro PCR values don't match any accepted measurements"},
+	VerifyErrorNonceMismatch:         {http.StatusUnprocessableEntity, "Nitro report nonce doesn't match the response nonce"},
+	VerifyErrorUserDataLength:        {http.StatusUnprocessableEntity, "report userData has an unexpected length"},
+	VerifyErrorReportInvalid:         {http.StatusUnprocessableEntity, "report is invalid"},
+	VerifyErrorProofDataPreparation:  {http.StatusUnprocessableEntity, "attestation response cannot be encoded"},
+	VerifyErrorProofDataFormatting:   {http.StatusInternalServerError, "proof data cannot be formatted as a Leo struct"},
+	VerifyErrorProofDataHashing:      {http.StatusInternalServerError, "proof data cannot be hashed"},
+	VerifyErrorUserDataHashMismatch:  {http.StatusUnprocessableEntity, "report userData doesn't match the attestation response"},
+	VerifyErrorAttestationTooOld:     {http.StatusUnprocessableEntity, "attestation timestamp is too old"},
+	VerifyErrorDocumentTooOld:        {http.StatusUnprocessableEntity, "Nitro attestation document is too old"},
+	VerifyErrorTimestampInFuture:     {http.StatusUnprocessableEntity, "timestamp is in the future"},
+	VerifyErrorNonceNotIssued:        {http.StatusUnprocessableEntity, "nonce was not issued by this backend"},
+	VerifyErrorNonceExpired:          {http.StatusUnprocessableEntity, "nonce has expired"},
+	VerifyErrorNonceReused:           {http.StatusUnprocessableEntity, "nonce was already used"},
+	VerifyErrorGroupReportInvalid:    {http.StatusUnprocessableEntity, "a report in the group is invalid"},
+	VerifyErrorSingleTeeGroup:        {http.StatusUnprocessableEntity, "group doesn't have reports from more than one TEE type"},
+	VerifyErrorGroupDataMismatch:     {http.StatusUnprocessableEntity, "reports in the group attest to different data"},
+	VerifyErrorGroupTimestamp:        {http.StatusUnprocessableEntity, "reports in the group have different timestamps"},
+	VerifyErrorGroupUserData:         {http.StatusUnprocessableEntity, "reports in the group have different userData hashes"},
+}
+
+// ApiError is an error from the error catalogue as returned by the API
+type ApiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+
+	status int
+}
+
+func (e *ApiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Status returns the HTTP status for the error
+func (e *ApiError) Status() int {
+	return e.status
+}
+
+// NewApiError creates an error from the catalogue. Unknown codes become internal errors
+func NewApiError(code string, details interface{}) *ApiError {
+	entry, ok := errorCatalogue[code]
+	if !ok {
+		code = ErrorInternal
+		entry = errorCatalogue[ErrorInternal]
+	}
+
+	return &ApiError{
+		Code:    code,
+		Message: entry.message,
+		Details: details,
+		status:  entry.status,
+	}
+}
+
+// ErrorResponse is the response body of a failed request
+type ErrorResponse struct {
+	Success bool      `json:"success"`
+	Error   *ApiError `json:"error"`
+	// same as Error.Message, for clients that don't read the error object
+	ErrorMessage string `json:"errorMessage"`
+}
+
+func respondError(ctx context.Context, w http.ResponseWriter, apiErr *ApiError) {
+	log := GetContextLogger(ctx)
+
+	msg, err := json.Marshal(&ErrorResponse{
+		Success:      false,
+		Error:        apiErr,
+		ErrorMessage: apiErr.Message,
+	})
+	if err != nil {
+		log.Println("failed to marshal error response:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	w.Write(msg)
+}
+
+// maps an error to one of the error codes above. Errors that are not in the catalogue are internal errors
+func errorCode(err error) string {
+	var decodeErr *attestation.DecodeError
+
+	switch {
+	case errors.Is(err, attestation.ErrUnsupportedReportType):
+		return VerifyErrorUnsupportedReportType
+	case errors.Is(err, sgx.ErrUniqueIdMismatch):
+		return VerifyErrorUniqueIdMismatch
+	case errors.Is(err, sgx.ErrSignerMismatch):
+		return VerifyErrorSignerMismatch
+	case errors.Is(err, sgx.ErrDebugEnclave):
+		return VerifyErrorDebugEnclave
+	case errors.Is(err, sgx.ErrSecurityVersionTooLow):
+		return VerifyErrorSecurityVersion
+	case errors.Is(err, sgx.ErrTcbStatusRejected):
+		return VerifyErrorTcbStatus
+	case errors.Is(err, nitro.ErrPcrValuesMismatch):
+		return VerifyErrorPcrMismatch
+	case errors.Is(err, nitro.ErrNonceMismatch):
+		return VerifyErrorNonceMismatch
+	case errors.Is(err, nitro.ErrUnexpectedUserDataLength):
+		return VerifyErrorUserDataLength
+	case errors.Is(err, attestation.ErrVerificationFailedToPrepare):
+		return VerifyErrorProofDataPreparation
+	case errors.Is(err, attestation.ErrVerificationFailedToFormat):
+		return VerifyErrorProofDataFormatting
+	case errors.Is(err, attestation.ErrVerificationFailedToHash):
+		return VerifyErrorProofDataHashing
+	case errors.Is(err, attestation.ErrVerificationFailedToMatchData):
+		return VerifyErrorUserDataHashMismatch
+	case errors.Is(err, attestation.ErrAttestationTooOld):
+		return VerifyErrorAttestationTooOld
+	case errors.Is(err, attestation.ErrDocumentTooOld):
+		return VerifyErrorDocumentTooOld
+	case errors.Is(err, attestation.ErrAttestationFromFuture), errors.Is(err, attestation.ErrDocumentFromFuture):
+		return VerifyErrorTimestampInFuture
+	case errors.Is(err, challenge.ErrUnknownNonce):
+		return VerifyErrorNonceNotIssued
+	case errors.Is(err, challenge.ErrNonceExpired):
+		return VerifyErrorNonceExpired
+	case errors.Is(err, challenge.ErrNonceUsed):
+		return VerifyErrorNonceReused
+	case errors.Is(err, challenge.ErrTooManyNonces):
+		return ErrorTooManyNonces
+	case errors.Is(err, challenge.ErrClientQuotaExceeded):
+		return ErrorTooManyClientNonces
+	case errors.Is(err, attestation.ErrSingleTeeGroup):
+		return VerifyErrorSingleTeeGroup
+	case errors.Is(err, attestation.ErrGroupAttestationDataMismatch):
+		return VerifyErrorGroupDataMismatch
+	case errors.Is(err, attestation.ErrGroupTimestampMismatch):
+		return VerifyErrorGroupTimestamp
+	case errors.Is(err, attestation.ErrGroupUserDataMismatch):
+		return VerifyErrorGroupUserData
+	case errors.Is(err, ErrUnsupportedDecodeFormat):
+		return ErrorUnsupportedDecodeFormat
+	case errors.Is(err, ErrInvalidUserData):
+		return ErrorInvalidUserData
+	case errors.Is(err, attestation.ErrProofDataTooShort):
+		return ErrorProofDataTooShort
+	case errors.Is(err, attestation.ErrFieldOutOfBounds):
+		return ErrorProofDataOutOfBounds
+	case errors.Is(err, attestation.ErrUnexpectedFieldLength):
+		return ErrorProofDataUnexpectedLen
+	case errors.Is(err, attestation.ErrMalformedField):
+		return ErrorProofDataMalformedField
+	case errors.As(err, &decodeErr):
+		return ErrorProofDataMalformedField
+	case errors.Is(err, contract.ErrTransactionNotFound):
+		return ErrorTransactionNotFound
+	case errors.Is(err, contract.ErrNoOracleTransition):
+		return ErrorNoOracleTransition
+	case errors.Is(err, contract.ErrInvalidTransitionInput):
+		return ErrorInvalidTransitionInput
+	case errors.Is(err, contract.ErrNodeApi):
+		return ErrorNodeApi
+	case errors.Is(err, leo.ErrSyntax), errors.Is(err, leo.ErrOutOfRange), errors.Is(err, leo.ErrInvalidAddress),
+		errors.Is(err, leo.ErrUnexpectedType), errors.Is(err, leo.ErrNoMember):
+		return ErrorInvalidLeoValue
+	case errors.Is(err, attestation.ErrReportInvalid):
+		return VerifyErrorReportInvalid
+	default:
+		return ErrorInternal
+	}
+}
+
+// creates an API error from an error returned by the attestation packages. Proof data decoding errors
+// have the failed field in the details, other errors have their text
+func apiErrorFromError(err error) *ApiError {
+	var decodeErr *attestation.DecodeError
+	if errors.As(err, &decodeErr) {
+		return NewApiError(errorCode(err), map[string]interface{}{
+			"field":          decodeErr.Field,
+			"offset":         decodeErr.Offset,
+			"expectedLength": decodeErr.ExpectedLen,
+			"actualLength":   decodeErr.ActualLen,
+			"reason":         decodeErr.Err.Error(),
+		})
+	}
+
+	return NewApiError(errorCode(err), err.Error())
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/nitro"
+	"github.com/zkportal/oracle-verification-backend/challenge"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/leo"
+)
+
+func TestApiErrorFromError(t *testing.T) {
+	_, decodeErr := attestation.DecodeProofData(nil)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   string
+		wantStatus int
+		// expected details, only checked if set
+		wantDetails string
+	}{
+		{
+			name:       "wrapped nonce mismatch",
+			err:        fmt.Errorf("report 1: %w", nitro.ErrNonceMismatch),
+			wantCode:   VerifyErrorNonceMismatch,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "pcr mismatch",
+			err:        nitro.ErrPcrValuesMismatch,
+			wantCode:   VerifyErrorPcrMismatch,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "userData hash mismatch",
+			err:        attestation.ErrVerificationFailedToMatchData,
+			wantCode:   VerifyErrorUserDataHashMismatch,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "replayed nonce",
+			err:        challenge.ErrNonceUsed,
+			wantCode:   VerifyErrorNonceReused,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "hashing failure",
+			err:        attestation.ErrVerificationFailedToHash,
+			wantCode:   VerifyErrorProofDataHashing,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:        "proof data preparation failure",
+			err:         fmt.Errorf("%w: %w", attestation.ErrVerificationFailedToPrepare, errors.New("unknown value encoding")),
+			wantCode:    VerifyErrorProofDataPreparation,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantDetails: attestation.ErrVerificationFailedToPrepare.Error() + ": unknown value encoding",
+		},
+		{
+			name:       "unsupported decode format",
+			err:        ErrUnsupportedDecodeFormat,
+			wantCode:   ErrorUnsupportedDecodeFormat,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "proof data decoding",
+			err:        decodeErr,
+			wantCode:   ErrorProofDataTooShort,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "missing transaction",
+			err:        fmt.Errorf("%w: at1abc", contract.ErrTransactionNotFound),
+			wantCode:   ErrorTransactionNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "node API failure",
+			err:        fmt.Errorf("%w: did not get an OK response", contract.ErrNodeApi),
+			wantCode:   ErrorNodeApi,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "invalid report",
+			err:        fmt.Errorf("%w: %w", attestation.ErrReportInvalid, errors.New("bad signature")),
+			wantCode:   VerifyErrorReportInvalid,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "decode error without a known reason",
+			err:        &attestation.DecodeError{Field: "attestation data", Err: errors.New("unknown")},
+			wantCode:   ErrorProofDataMalformedField,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Leo syntax error",
+			err:        fmt.Errorf("%w at offset 3: unexpected '}'", leo.ErrSyntax),
+			wantCode:   ErrorInvalidLeoValue,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Leo value out of range",
+			err:        fmt.Errorf("%w: 256u8", leo.ErrOutOfRange),
+			wantCode:   ErrorInvalidLeoValue,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Leo struct without a member",
+			err:        fmt.Errorf("%w: c0", leo.ErrNoMember),
+			wantCode:   ErrorInvalidLeoValue,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "unknown error",
+			err:        errors.New("unknown"),
+			wantCode:   ErrorInternal,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := apiErrorFromError(tt.err)
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("expected code %s, got %s", tt.wantCode, apiErr.Code)
+			}
+
+			if apiErr.Status() != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, apiErr.Status())
+			}
+
+			if apiErr.Details == nil {
+				t.Error("expected error details")
+			}
+
+			if tt.wantDetails != "" && apiErr.Details != tt.wantDetails {
+				t.Errorf("expected details %q, got %v", tt.wantDetails, apiErr.Details)
+			}
+		})
+	}
+}
+
+func TestNewApiErrorUnknownCode(t *testing.T) {
+	apiErr := NewApiError("NOT_IN_CATALOGUE", nil)
+	if apiErr.Code != ErrorInternal || apiErr.Status() != http.StatusInternalServerError {
+		t.Errorf("expected an internal error, got %s with status %d", apiErr.Code, apiErr.Status())
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zkportal/oracle-verification-backend/history"
+)
+
+type historyHandler struct {
+	store history.Store
+}
+
+type HistoryRequest struct {
+	history.Filter
+}
+
+type HistoryResponse struct {
+	Records []history.Record `json:"records"`
+	// pass as "beforeId" to get the next page, 0 if there are no more records
+	NextBeforeID uint64 `json:"nextBeforeId,omitempty"`
+	Success      bool   `json:"success"`
+}
+
+// adds verification records to the history. A failure is logged and doesn't fail the request
+func recordHistory(ctx context.Context, store history.Store, records ...*history.Record) {
+	if store == nil || len(records) == 0 {
+		return
+	}
+
+	requestId := GetContextRequestId(ctx)
+	for _, r := range records {
+		r.RequestID = requestId
+	}
+
+	if err := store.Add(records...); err != nil {
+		GetContextLogger(ctx).Println("failed to add verification history records:", err)
+	}
+}
+
+func validateHistoryRequest(request *HistoryRequest) error {
+	if request.Limit < 0 || request.Limit > history.MaxQueryLimit {
+		return fmt.Errorf("\"limit\" must be between 0 and %d", history.MaxQueryLimit)
+	}
+
+	if request.From != nil && request.To != nil && !request.From.Before(*request.To) {
+		return fmt.Errorf("\"from\" must be before \"to\"")
+	}
+
+	return nil
+}
+
+func (hh *historyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	request := new(HistoryRequest)
+	if !readJsonRequest(w, req, request) {
+		return
+	}
+
+	log := GetContextLogger(req.Context())
+
+	if err := validateHistoryRequest(request); err != nil {
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, err.Error()))
+		return
+	}
+
+	records, err := hh.store.Query(&request.Filter)
+	if err != nil {
+		log.Println("failed to query verification history:", err)
+		respondError(req.Context(), w, NewApiError(ErrorInternal, nil))
+		return
+	}
+
+	response := &HistoryResponse{
+		Records: records,
+		Success: true,
+	}
+
+	limit := request.Limit
+	if limit == 0 {
+		limit = history.DefaultQueryLimit
+	}
+	if len(records) == limit {
+		response.NextBeforeID = records[len(records)-1].ID
+	}
+
+	msg, err := json.Marshal(response)
+	if err != nil {
+		log.Println("failed to marshal response:", err)
+		respondError(req.Context(), w, NewApiError(ErrorInternal, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(msg)
+}
+
+// CreateHistoryHandler creates a handler that returns the verification history, newest first
+func CreateHistoryHandler(store history.Store) http.Handler {
+	return &historyHandler{
+		store: store,
+	}
+}
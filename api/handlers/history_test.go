@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	store := history.NewMemoryStore(history.Retention{})
+
+	verifyHandler := CreateVerifyHandler(pool, nil, nil, nil, nil, false, 1, 0, 0, store)
+	historyHandler := CreateHistoryHandler(store)
+
+	post := func(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), ContextRequestID, "test-request"))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := post(verifyHandler, "/verify", `{"reports": [
+		{"reportType": "sgx", "attestationReport": "not base64", "attestationRequest": {"url": "a.com"}},
+		{"reportType": "tdx", "attestationReport": "AAAA", "timestamp": 1701851063, "attestationRequest": {"url": "b.com"}}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantUrls   []string
+		wantNext   uint64
+	}{
+		{
+			name:       "all",
+			body:       `{}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{"b.com", "a.com"},
+		},
+		{
+			name:       "by url",
+			body:       `{"url": "a.com"}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{"a.com"},
+		},
+		{
+			name:       "by report type and verdict",
+			body:       `{"reportType": "tdx", "valid": false}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{"b.com"},
+		},
+		{
+			name:       "valid only",
+			body:       `{"valid": true}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{},
+		},
+		{
+			name:       "first page",
+			body:       `{"limit": 1}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{"b.com"},
+			wantNext:   2,
+		},
+		{
+			name:       "next page",
+			body:       `{"limit": 1, "beforeId": 2}`,
+			wantStatus: http.StatusOK,
+			wantUrls:   []string{"a.com"},
+			wantNext:   1,
+		},
+		{
+			name:       "limit too large",
+			body:       `{"limit": 100000}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty time range",
+			body:       `{"from": "2024-01-02T00:00:00Z", "to": "2024-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(historyHandler, "/history", tt.body)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			resp := new(HistoryResponse)
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Records) != len(tt.wantUrls) {
+				t.Fatalf("got %d records, want %d: %+v", len(resp.Records), len(tt.wantUrls), resp.Records)
+			}
+
+			for i, r := range resp.Records {
+				if r.Url != tt.wantUrls[i] {
+					t.Errorf("record %d url = %s, want %s", i, r.Url, tt.wantUrls[i])
+				}
+				if r.RequestID != "test-request" || r.Source != history.SOURCE_VERIFY || r.Valid || r.ErrorCode == "" {
+					t.Errorf("unexpected record %+v", r)
+				}
+			}
+
+			if resp.NextBeforeID != tt.wantNext {
+				t.Errorf("nextBeforeId = %d, want %d", resp.NextBeforeID, tt.wantNext)
+			}
+		})
+	}
+}
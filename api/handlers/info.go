@@ -8,22 +8,36 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/zkportal/oracle-verification-backend/attestation"
 	"github.com/zkportal/oracle-verification-backend/attestation/nitro"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/liveCheck"
 	"github.com/zkportal/oracle-verification-backend/u128"
 )
 
+// Service statuses in /info
+const (
+	SERVICE_STATUS_OK       = "ok"
+	SERVICE_STATUS_DEGRADED = "degraded"
+)
+
 type infoHandler struct {
-	uniqueId         string
-	pcrValues        [3]string
+	measurements     *attestation.MeasurementRegistry
+	priceFeeds       []attestation.PriceFeed
 	liveCheckProgram string
-	startTime        time.Time
+	// nil if the live contract is not checked
+	refresher *liveCheck.Refresher
+	client    *contract.Client
+	startTime time.Time
 }
 
-func CreateInfoHandler(uniqueId string, pcrValues [3]string, liveCheckProgram string) http.Handler {
+func CreateInfoHandler(measurements *attestation.MeasurementRegistry, priceFeeds []attestation.PriceFeed, liveCheckProgram string, refresher *liveCheck.Refresher, client *contract.Client) http.Handler {
 	return &infoHandler{
-		uniqueId:         uniqueId,
-		pcrValues:        pcrValues,
+		measurements:     measurements,
+		priceFeeds:       priceFeeds,
 		liveCheckProgram: liveCheckProgram,
+		refresher:        refresher,
+		client:           client,
 		startTime:        time.Now().UTC(),
 	}
 }
@@ -40,43 +54,52 @@ type pcrValuesInfo struct {
 	Aleo   string    `json:"aleoEncoded"`
 }
 
-type InfoResponse struct {
-	TargetUniqueId   uniqueIdInfo  `json:"targetUniqueId"`
-	TargetPcrValues  pcrValuesInfo `json:"targetPcrValues"`
-	LiveCheckProgram string        `json:"liveCheckProgram"`
-	StartTime        string        `json:"startTimeUTC"`
-}
-
-func (h *infoHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
+type measurementSetInfo struct {
+	attestation.MeasurementSet
 
-	log := GetContextLogger(req.Context())
+	// whether the set's validity window includes the current time
+	Valid bool `json:"valid"`
+}
 
-	response := new(InfoResponse)
+type InfoResponse struct {
+	// the first measurement sets with SGX and Nitro measurements
+	TargetUniqueId   *uniqueIdInfo           `json:"targetUniqueId"`
+	TargetPcrValues  *pcrValuesInfo          `json:"targetPcrValues"`
+	Measurements     []measurementSetInfo    `json:"measurements"`
+	PriceFeeds       []attestation.PriceFeed `json:"priceFeeds"`
+	LiveCheckProgram string                  `json:"liveCheckProgram"`
+	StartTime        string                  `json:"startTimeUTC"`
+	// one of the SERVICE_STATUS_ values
+	Status string `json:"status"`
+	// result of the last checks of the live contract, omitted if the check is skipped
+	LiveCheck *liveCheck.Status `json:"liveCheck,omitempty"`
+	// request counters and circuit breaker states of the Aleo node API endpoints
+	NodeApi *contract.ClientMetrics `json:"nodeApi,omitempty"`
+}
 
-	uniqueIdBytes, _ := hex.DecodeString(h.uniqueId)
+func getUniqueIdInfo(uniqueId string) *uniqueIdInfo {
+	uniqueIdBytes, _ := hex.DecodeString(uniqueId)
 
 	uniqueIdAleo1, _ := u128.SliceToU128(uniqueIdBytes[0:16])
 	uniqueIdAleo2, _ := u128.SliceToU128(uniqueIdBytes[16:32])
 
-	response.TargetUniqueId = uniqueIdInfo{
-		Hex:    h.uniqueId,
+	return &uniqueIdInfo{
+		Hex:    uniqueId,
 		Base64: base64.StdEncoding.EncodeToString(uniqueIdBytes),
 		Aleo:   fmt.Sprintf("{ chunk_1: %su128, chunk_2: %su128 }", uniqueIdAleo1.String(), uniqueIdAleo2.String()),
 	}
+}
 
+func getPcrValuesInfo(pcrValues []string) *pcrValuesInfo {
 	var pcrBytes [3][48]byte
 
-	for idx, pcr := range h.pcrValues {
+	for idx, pcr := range pcrValues {
 		buf, _ := hex.DecodeString(pcr)
 		pcrBytes[idx] = ([48]byte)(buf)
 	}
 
-	response.TargetPcrValues = pcrValuesInfo{
-		Hex: h.pcrValues,
+	return &pcrValuesInfo{
+		Hex: [3]string(pcrValues),
 		Base64: [3]string{
 			base64.StdEncoding.EncodeToString(pcrBytes[0][:]),
 			base64.StdEncoding.EncodeToString(pcrBytes[1][:]),
@@ -84,21 +107,58 @@ func (h *infoHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		},
 		Aleo: nitro.FormatPcrValues(pcrBytes),
 	}
+}
+
+func (h *infoHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		respondError(req.Context(), w, NewApiError(ErrorMethodNotAllowed, nil))
+		return
+	}
+
+	log := GetContextLogger(req.Context())
+
+	response := new(InfoResponse)
+
+	now := time.Now()
+
+	measurements := h.measurements.Sets()
+
+	response.Measurements = make([]measurementSetInfo, 0, len(measurements))
+	for _, m := range measurements {
+		if response.TargetUniqueId == nil && m.HasSgx() {
+			response.TargetUniqueId = getUniqueIdInfo(m.UniqueID)
+		}
+		if response.TargetPcrValues == nil && m.HasNitro() {
+			response.TargetPcrValues = getPcrValuesInfo(m.PcrValues)
+		}
+
+		response.Measurements = append(response.Measurements, measurementSetInfo{
+			MeasurementSet: m,
+			Valid:          m.IsValidAt(now),
+		})
+	}
 
+	response.PriceFeeds = h.priceFeeds
 	response.LiveCheckProgram = h.liveCheckProgram
 	response.StartTime = h.startTime.Format(time.DateTime)
 
+	response.Status = SERVICE_STATUS_OK
+	response.LiveCheck = h.refresher.Status()
+	response.NodeApi = h.client.Metrics()
+	if response.LiveCheck != nil && response.LiveCheck.Degraded {
+		response.Status = SERVICE_STATUS_DEGRADED
+	}
+
 	responseBody, err := json.Marshal(response)
 	if err != nil {
 		log.Println("failed to marshal response:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(req.Context(), w, NewApiError(ErrorInternal, nil))
 		return
 	}
 
 	_, err = w.Write(responseBody)
 	if err != nil {
 		log.Println("failed to write response:", err)
-		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 }
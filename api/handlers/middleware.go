@@ -87,7 +87,7 @@ func PanicMiddleware(next http.Handler) http.HandlerFunc {
 				log.Println("Panic:", err)
 				log.Printf("%s", debug.Stack())
 
-				w.WriteHeader(http.StatusInternalServerError)
+				respondError(r.Context(), w, NewApiError(ErrorInternal, nil))
 			}
 		}()
 
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/challenge"
+)
+
+type NonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// identifies the client by its IP address. An IPv6 client usually has a whole /64 network, so it's identified by the network
+func clientKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+
+	return ip.String()
+}
+
+func CreateNonceHandler(challenger *challenge.Challenger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			respondError(req.Context(), w, NewApiError(ErrorMethodNotAllowed, nil))
+			return
+		}
+
+		log := GetContextLogger(req.Context())
+
+		nonce, expiresAt, err := challenger.Issue(clientKey(req))
+		if err != nil {
+			log.Println("failed to issue nonce:", err)
+			respondError(req.Context(), w, NewApiError(errorCode(err), nil))
+			return
+		}
+
+		msg, err := json.Marshal(&NonceResponse{
+			Nonce:     nonce,
+			ExpiresAt: expiresAt.UTC(),
+		})
+		if err != nil {
+			log.Println("failed to marshal response:", err)
+			respondError(req.Context(), w, NewApiError(ErrorInternal, nil))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(msg)
+	}
+}
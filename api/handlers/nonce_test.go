@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/challenge"
+)
+
+func TestNonceHandler_MaxOutstanding(t *testing.T) {
+	handler := CreateNonceHandler(challenge.NewChallenger(challenge.NewMemoryStore(1, 0), time.Minute))
+
+	wantStatus := []int{http.StatusOK, http.StatusServiceUnavailable}
+	for idx, want := range wantStatus {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/nonce", nil))
+
+		if w.Code != want {
+			t.Fatalf("request %d status = %d, want %d", idx, w.Code, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/nonce", nil))
+
+	response := new(ErrorResponse)
+	if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Error == nil || response.Error.Code != ErrorTooManyNonces {
+		t.Errorf("error = %+v, want code %s", response.Error, ErrorTooManyNonces)
+	}
+}
+
+func TestNonceHandler_MaxPerClient(t *testing.T) {
+	handler := CreateNonceHandler(challenge.NewChallenger(challenge.NewMemoryStore(0, 1), time.Minute))
+
+	tests := []struct {
+		remoteAddr string
+		wantStatus int
+	}{
+		{remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusOK},
+		// another port of the same host
+		{remoteAddr: "192.0.2.1:4321", wantStatus: http.StatusTooManyRequests},
+		{remoteAddr: "192.0.2.2:1234", wantStatus: http.StatusOK},
+		{remoteAddr: "[2001:db8::1]:1234", wantStatus: http.StatusOK},
+		// another address in the same /64 network
+		{remoteAddr: "[2001:db8::2]:1234", wantStatus: http.StatusTooManyRequests},
+		{remoteAddr: "[2001:db8:0:1::1]:1234", wantStatus: http.StatusOK},
+	}
+
+	for idx, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/nonce", nil)
+		req.RemoteAddr = tt.remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("request %d from %s status = %d, want %d", idx, tt.remoteAddr, w.Code, tt.wantStatus)
+		}
+	}
+}
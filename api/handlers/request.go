@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// checks that the request is a JSON POST request and unmarshals its body into request.
+// Responds with an error and returns false if the request cannot be read
+func readJsonRequest(w http.ResponseWriter, req *http.Request, request interface{}) bool {
+	ctx := req.Context()
+
+	if req.Method != http.MethodPost {
+		respondError(ctx, w, NewApiError(ErrorMethodNotAllowed, nil))
+		return false
+	}
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		respondError(ctx, w, NewApiError(ErrorUnsupportedContentType, nil))
+		return false
+	}
+
+	log := GetContextLogger(ctx)
+
+	body, err := io.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		log.Println("error reading request body:", err)
+		respondError(ctx, w, NewApiError(ErrorInvalidRequestBody, err.Error()))
+		return false
+	}
+
+	err = json.Unmarshal(body, request)
+	if err != nil {
+		log.Println("error reading request", err)
+		respondError(ctx, w, NewApiError(ErrorInvalidRequestBody, err.Error()))
+		return false
+	}
+
+	return true
+}
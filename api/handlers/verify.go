@@ -3,37 +3,109 @@ package handlers
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/challenge"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
 
 	aleo_wrapper "github.com/zkportal/aleo-utils-go"
 )
 
 type verifyHandler struct {
-	aleoWrapper     aleo_wrapper.Wrapper
-	targetUniqueId  string
-	targetPcrValues [3]string
+	sessionPool  *sessionPool.Pool
+	measurements *attestation.MeasurementRegistry
+	sgxPolicy    *sgx.Policy
+	freshness    *attestation.FreshnessPolicy
+	challenger   *challenge.Challenger
+	// whether all Nitro reports must carry a nonce issued by the challenger
+	requireNonce bool
+	workers      int
+	maxReports   int
+	// how long a request waits for Aleo sessions for its workers
+	sessionWait time.Duration
+	history     history.Store
+
+	// verifies a report, replaced in tests
+	verify func(reportType string, report []byte, nonce string, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*attestation.VerifiedReport, error)
 }
 
 type VerifyReportsRequest struct {
 	Reports []attestation.AttestationResponse `json:"reports"`
+	// if true, every result includes the attested details of the report
+	IncludeReportDetails bool `json:"includeReportDetails,omitempty"`
+	// additionally verify the reports as of this time, only if the freshness policy allows it.
+	// Reports must match a measurement set that is valid both now and at this time, and be fresh at both times
+	AsOf *time.Time `json:"asOf,omitempty"`
+	// if true, Nitro reports must have a nonce issued by /nonce that wasn't used before
+	RequireServerNonce bool `json:"requireServerNonce,omitempty"`
+	// if true, reports are grouped by attestation request, and every group is checked for
+	// agreement between reports from different TEE types
+	CheckConsistency bool `json:"checkConsistency,omitempty"`
 }
 
-type VerifyReportsResponse struct {
-	Success      bool   `json:"success"`
-	ValidReports []int  `json:"validReports"`
+// per-request options shared by all report verifications
+type verifyOptions struct {
+	includeDetails bool
+	referenceTime  time.Time
+	// client-provided time that the reports must also be acceptable at
+	asOf         *time.Time
+	requireNonce bool
+}
+
+// Verification verdict for a single report in the request
+type VerifyReportResult struct {
+	Index        int    `json:"index"`
+	ReportType   string `json:"reportType"`
+	Valid        bool   `json:"valid"`
+	ErrorCode    string `json:"errorCode,omitempty"`
 	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// label and source of the measurement set that the report matched
+	MatchedMeasurements string `json:"matchedMeasurements,omitempty"`
+	MeasurementsSource  string `json:"measurementsSource,omitempty"`
+
+	Details *attestation.ReportDetails `json:"details,omitempty"`
+
+	// user data of a valid report, used for consistency checks
+	userData []byte
+	// hash of the decoded report for the verification history
+	reportHash string
 }
 
-func respondVerify(ctx context.Context, w http.ResponseWriter, validReports []int, errors string) {
+// Consistency verdict for reports with the same attestation request
+type ConsistencyGroupResult struct {
+	Reports           []int    `json:"reports"`
+	ReportTypes       []string `json:"reportTypes"`
+	MultiTeeConfirmed bool     `json:"multiTeeConfirmed"`
+	ErrorCode         string   `json:"errorCode,omitempty"`
+	ErrorMessage      string   `json:"errorMessage,omitempty"`
+}
+
+type VerifyReportsResponse struct {
+	Success      bool                 `json:"success"`
+	ValidReports []int                `json:"validReports"`
+	Results      []VerifyReportResult `json:"results"`
+	// only set if the request has "checkConsistency"
+	Groups       []ConsistencyGroupResult `json:"groups,omitempty"`
+	ErrorMessage string                   `json:"errorMessage,omitempty"`
+}
+
+func respondVerify(ctx context.Context, w http.ResponseWriter, validReports []int, results []VerifyReportResult, groups []ConsistencyGroupResult, errors string) {
 	log := GetContextLogger(ctx)
 
 	r := &VerifyReportsResponse{
 		ValidReports: validReports,
+		Results:      results,
+		Groups:       groups,
 		Success:      true,
 	}
 
@@ -45,7 +117,7 @@ func respondVerify(ctx context.Context, w http.ResponseWriter, validReports []in
 	msg, err := json.Marshal(r)
 	if err != nil {
 		log.Println("failed to marshal response:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(ctx, w, NewApiError(ErrorInternal, nil))
 		return
 	}
 
@@ -53,82 +125,309 @@ func respondVerify(ctx context.Context, w http.ResponseWriter, validReports []in
 	w.Write(msg)
 }
 
-func CreateVerifyHandler(aleoWrapper aleo_wrapper.Wrapper, uniqueId string, pcrValues [3]string) http.Handler {
+func CreateVerifyHandler(pool *sessionPool.Pool, measurements *attestation.MeasurementRegistry, sgxPolicy *sgx.Policy, freshness *attestation.FreshnessPolicy, challenger *challenge.Challenger, requireNonce bool, workers, maxReports int, sessionWait time.Duration, historyStore history.Store) http.Handler {
 	return &verifyHandler{
-		aleoWrapper:     aleoWrapper,
-		targetUniqueId:  uniqueId,
-		targetPcrValues: pcrValues,
+		sessionPool:  pool,
+		measurements: measurements,
+		sgxPolicy:    sgxPolicy,
+		freshness:    freshness,
+		challenger:   challenger,
+		requireNonce: requireNonce,
+		workers:      max(workers, 1),
+		maxReports:   maxReports,
+		sessionWait:  sessionWait,
+		history:      historyStore,
+		verify:       attestation.VerifyReport,
 	}
 }
 
 func (vh *verifyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-
-	if req.Header.Get("Content-Type") != "application/json" {
-		w.WriteHeader(http.StatusBadRequest)
+	request := new(VerifyReportsRequest)
+	if !readJsonRequest(w, req, request) {
 		return
 	}
 
 	log := GetContextLogger(req.Context())
 
-	body, err := io.ReadAll(req.Body)
-	defer req.Body.Close()
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if len(request.Reports) == 0 {
+		log.Println("no reports to verify")
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, "\"reports\" must not be empty"))
 		return
 	}
 
-	request := new(VerifyReportsRequest)
-	err = json.Unmarshal(body, request)
-	if err != nil {
-		log.Println("error reading request", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if vh.maxReports > 0 && len(request.Reports) > vh.maxReports {
+		log.Println("too many reports to verify:", len(request.Reports))
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, fmt.Sprintf("\"reports\" must have at most %d reports", vh.maxReports)))
 		return
 	}
 
-	if len(request.Reports) == 0 {
-		log.Println("no reports to verify")
-		w.WriteHeader(http.StatusBadRequest)
+	if request.AsOf != nil && (vh.freshness == nil || !vh.freshness.AllowAsOf) {
+		log.Println("asOf is not allowed")
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, "\"asOf\" is not enabled"))
 		return
 	}
 
-	aleoSession, err := vh.aleoWrapper.NewSession()
+	numWorkers := min(vh.workers, len(request.Reports), vh.sessionPool.Size())
+	sessions, err := vh.getSessions(req.Context(), numWorkers)
+	defer func() {
+		for _, session := range sessions {
+			vh.sessionPool.Put(session)
+		}
+	}()
 	if err != nil {
-		log.Println("error creating new aleo session:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Println("error getting aleo session:", err)
+		respondError(req.Context(), w, NewApiError(ErrorServiceUnavailable, nil))
 		return
 	}
-	defer aleoSession.Close()
+
+	opts := &verifyOptions{
+		includeDetails: request.IncludeReportDetails,
+		referenceTime:  time.Now(),
+		asOf:           request.AsOf,
+		requireNonce:   vh.requireNonce || request.RequireServerNonce,
+	}
+
+	results := vh.verifyReports(req.Context(), sessions, request.Reports, opts)
+
+	vh.recordHistory(req.Context(), request.Reports, results, opts.referenceTime)
 
 	validReports := make([]int, 0)
 	var errors []string
-	for i, v := range request.Reports {
-		reportBytes, err := base64.StdEncoding.DecodeString(v.AttestationReport)
+	for _, result := range results {
+		if result.Valid {
+			validReports = append(validReports, result.Index)
+		} else {
+			errors = append(errors, result.ErrorMessage)
+		}
+	}
+
+	var groups []ConsistencyGroupResult
+	if request.CheckConsistency {
+		groups, err = checkConsistency(request.Reports, results)
 		if err != nil {
-			log.Printf("failed to decode base64 %s report: %s\n", v.ReportType, err)
-			errors = append(errors, err.Error())
-			break
+			log.Println("error checking report consistency:", err)
+			respondError(req.Context(), w, NewApiError(ErrorInternal, nil))
+			return
 		}
+	}
 
-		_, userData, err := attestation.VerifyReport(v.ReportType, reportBytes, v.Nonce, vh.targetUniqueId, vh.targetPcrValues)
+	respondVerify(req.Context(), w, validReports, results, groups, strings.Join(errors, "; "))
+}
+
+// borrows a session for every worker. Aleo sessions are not goroutine-safe, every worker gets its own.
+// When the pool is busy, waits for sessions until the session wait deadline, then continues with the sessions it has,
+// so that concurrent requests holding a part of the pool cannot deadlock. Fails only if there are no sessions at the deadline
+func (vh *verifyHandler) getSessions(ctx context.Context, numWorkers int) ([]aleo_wrapper.Session, error) {
+	if vh.sessionWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, vh.sessionWait)
+		defer cancel()
+	}
+
+	sessions := make([]aleo_wrapper.Session, 0, numWorkers)
+	for len(sessions) < numWorkers {
+		aleoSession, err := vh.sessionPool.Get(ctx)
 		if err != nil {
-			log.Printf("error verifying %s report: %s\n", v.ReportType, err)
-			errors = append(errors, err.Error())
+			if len(sessions) == 0 {
+				return nil, err
+			}
+
+			GetContextLogger(ctx).Printf("verifying with %d of %d workers: %s\n", len(sessions), numWorkers, err)
 			break
 		}
 
-		err = attestation.VerifyReportData(aleoSession, userData, &v)
+		sessions = append(sessions, aleoSession)
+	}
+
+	return sessions, nil
+}
+
+// verifies reports concurrently, one worker per session. The results are in the same order as the reports
+func (vh *verifyHandler) verifyReports(ctx context.Context, sessions []aleo_wrapper.Session, reports []attestation.AttestationResponse, opts *verifyOptions) []VerifyReportResult {
+	results := make([]VerifyReportResult, len(reports))
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session aleo_wrapper.Session) {
+			defer wg.Done()
+
+			for idx := range jobs {
+				results[idx] = vh.verifyReport(ctx, session, idx, &reports[idx], opts)
+			}
+		}(session)
+	}
+
+	for idx := range reports {
+		jobs <- idx
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return results
+}
+
+// verifies one report and its attested data, never stops at the first error so that
+// every report in a batch gets a verdict
+func (vh *verifyHandler) verifyReport(ctx context.Context, aleoSession aleo_wrapper.Session, idx int, v *attestation.AttestationResponse, opts *verifyOptions) (result VerifyReportResult) {
+	log := GetContextLogger(ctx)
+
+	result = VerifyReportResult{
+		Index:      idx,
+		ReportType: v.ReportType,
+	}
+
+	// this runs in a worker goroutine, PanicMiddleware cannot recover from panics here
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("report %d: panic while verifying %s report: %v\n", idx, v.ReportType, err)
+			result.Valid = false
+			result.ErrorCode = VerifyErrorReportInvalid
+			result.ErrorMessage = "internal error while verifying report"
+		}
+	}()
+
+	fail := func(err error) VerifyReportResult {
+		result.ErrorCode = errorCode(err)
+		result.ErrorMessage = err.Error()
+		return result
+	}
+
+	reportBytes, err := base64.StdEncoding.DecodeString(v.AttestationReport)
+	if err != nil {
+		log.Printf("report %d: failed to decode base64 %s report: %s\n", idx, v.ReportType, err)
+		result.ErrorCode = VerifyErrorInvalidReportEncoding
+		result.ErrorMessage = err.Error()
+		return result
+	}
+
+	result.reportHash = history.HashReport(reportBytes)
+
+	// "as of" can only narrow the accepted measurement sets
+	measurements := vh.measurements.Sets()
+	if opts.asOf != nil {
+		measurements = attestation.MeasurementsValidAt(measurements, *opts.asOf)
+	}
+
+	verifiedReport, err := vh.verify(v.ReportType, reportBytes, v.Nonce, measurements, vh.sgxPolicy, opts.referenceTime)
+	if err != nil {
+		log.Printf("report %d: error verifying %s report: %s\n", idx, v.ReportType, err)
+		return fail(err)
+	}
+
+	if verifiedReport.Measurements != nil {
+		result.MatchedMeasurements = verifiedReport.Measurements.Label
+		result.MeasurementsSource = verifiedReport.Measurements.Source
+	}
+
+	if opts.includeDetails {
+		result.Details, err = attestation.GetReportDetails(verifiedReport.Report)
 		if err != nil {
-			log.Printf("error verifying %s report: %s\n", v.ReportType, err)
-			errors = append(errors, err.Error())
-			break
+			log.Printf("report %d: failed to get %s report details: %s\n", idx, v.ReportType, err)
+		}
+	}
+
+	err = attestation.VerifyReportData(aleoSession, verifiedReport.UserData, v)
+	if err != nil {
+		log.Printf("report %d: error verifying %s report: %s\n", idx, v.ReportType, err)
+		return fail(err)
+	}
+
+	// the attestation timestamp is authenticated by the report data
+	err = attestation.CheckFreshness(v, verifiedReport, vh.freshness, opts.referenceTime)
+	if err == nil && opts.asOf != nil {
+		err = attestation.CheckFreshness(v, verifiedReport, vh.freshness, *opts.asOf)
+	}
+	if err != nil {
+		log.Printf("report %d: %s report is not fresh: %s\n", idx, v.ReportType, err)
+		return fail(err)
+	}
+
+	// redeem the nonce last so that an invalid report doesn't use it up
+	if opts.requireNonce && v.ReportType == attestation.TEE_TYPE_NITRO {
+		err = vh.challenger.Redeem(hex.EncodeToString(verifiedReport.Nonce))
+		if err != nil {
+			log.Printf("report %d: %s report nonce is rejected: %s\n", idx, v.ReportType, err)
+			return fail(err)
+		}
+	}
+
+	result.Valid = true
+	result.userData = verifiedReport.UserData
+
+	return result
+}
+
+// adds the verdicts to the verification history. The URL and timestamp of an invalid report are as claimed in the request
+func (vh *verifyHandler) recordHistory(ctx context.Context, reports []attestation.AttestationResponse, results []VerifyReportResult, referenceTime time.Time) {
+	records := make([]*history.Record, len(results))
+	for idx, result := range results {
+		records[idx] = &history.Record{
+			Source:               history.SOURCE_VERIFY,
+			ReportType:           result.ReportType,
+			ReportHash:           result.reportHash,
+			MatchedMeasurements:  result.MatchedMeasurements,
+			MeasurementsSource:   result.MeasurementsSource,
+			Url:                  reports[idx].AttestationRequest.Url,
+			AttestationTimestamp: reports[idx].Timestamp,
+			ReferenceTime:        referenceTime.UTC(),
+			Valid:                result.Valid,
+			ErrorCode:            result.ErrorCode,
+			ErrorMessage:         result.ErrorMessage,
+		}
+	}
+
+	recordHistory(ctx, vh.history, records...)
+}
+
+// groups reports by attestation request and checks that the valid reports in every group agree with each other.
+// A group is confirmed if all of its reports are valid, consistent, and come from more than one TEE type
+func checkConsistency(reports []attestation.AttestationResponse, results []VerifyReportResult) ([]ConsistencyGroupResult, error) {
+	groups, err := attestation.GroupReports(reports)
+	if err != nil {
+		return nil, err
+	}
+
+	groupResults := make([]ConsistencyGroupResult, 0, len(groups))
+	for _, group := range groups {
+		groupResult := ConsistencyGroupResult{
+			Reports:     group,
+			ReportTypes: make([]string, 0, len(group)),
+		}
+
+		members := make([]attestation.ConsistencyMember, 0, len(group))
+		for _, idx := range group {
+			groupResult.ReportTypes = append(groupResult.ReportTypes, reports[idx].ReportType)
+
+			if !results[idx].Valid {
+				if groupResult.ErrorCode == "" {
+					groupResult.ErrorCode = VerifyErrorGroupReportInvalid
+					groupResult.ErrorMessage = fmt.Sprintf("report %d is invalid", idx)
+				}
+				continue
+			}
+
+			members = append(members, attestation.ConsistencyMember{
+				Response: &reports[idx],
+				UserData: results[idx].userData,
+			})
+		}
+
+		if groupResult.ErrorCode == "" {
+			err = attestation.CheckGroupConsistency(members)
+			if err != nil {
+				groupResult.ErrorCode = errorCode(err)
+				groupResult.ErrorMessage = err.Error()
+			} else {
+				groupResult.MultiTeeConfirmed = true
+			}
 		}
 
-		validReports = append(validReports, i)
+		groupResults = append(groupResults, groupResult)
 	}
 
-	respondVerify(req.Context(), w, validReports, strings.Join(errors, "; "))
+	return groupResults, nil
 }
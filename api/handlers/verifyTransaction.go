@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+type verifyTransactionHandler struct {
+	pool         *sessionPool.Pool
+	measurements *attestation.MeasurementRegistry
+	sgxPolicy    *sgx.Policy
+	client       *contract.Client
+	contractName string
+	functions    contract.UpdateFunctions
+	// how long a request waits for the Aleo node API
+	nodeTimeout time.Duration
+	history     history.Store
+}
+
+type VerifyTransactionRequest struct {
+	TransactionID string `json:"transactionId"`
+}
+
+// VerifiedUpdate is an oracle update transition that passed verification
+type VerifiedUpdate struct {
+	Transition *contract.OracleTransition `json:"transition"`
+
+	// label and source of the measurement set that the report matched
+	MatchedMeasurements string `json:"matchedMeasurements,omitempty"`
+	MeasurementsSource  string `json:"measurementsSource,omitempty"`
+
+	Details     *attestation.ReportDetails    `json:"details,omitempty"`
+	DecodedData *attestation.DecodedProofData `json:"decodedData"`
+	Hash        *ProofDataHash                `json:"hash"`
+}
+
+type VerifyTransactionResponse struct {
+	// every oracle update transition in the transaction, in the order of execution
+	Updates []VerifiedUpdate `json:"updates"`
+	Success bool             `json:"success"`
+}
+
+func respondVerifyTransaction(ctx context.Context, w http.ResponseWriter, r *VerifyTransactionResponse) {
+	log := GetContextLogger(ctx)
+
+	msg, err := json.Marshal(r)
+	if err != nil {
+		log.Println("failed to marshal response:", err)
+		respondError(ctx, w, NewApiError(ErrorInternal, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(msg)
+}
+
+func (vh *verifyTransactionHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	request := new(VerifyTransactionRequest)
+	if !readJsonRequest(w, req, request) {
+		return
+	}
+
+	log := GetContextLogger(req.Context())
+
+	if request.TransactionID == "" {
+		respondError(req.Context(), w, NewApiError(ErrorInvalidRequest, "\"transactionId\" must not be empty"))
+		return
+	}
+
+	// the node API can be slow, the transaction is retrieved before taking a session that other requests need,
+	// and only for as long as the response can still be written
+	nodeCtx, cancel := context.WithTimeout(req.Context(), vh.nodeTimeout)
+	defer cancel()
+
+	transitions, err := vh.client.GetOracleTransitions(nodeCtx, vh.contractName, vh.functions, request.TransactionID)
+	if err != nil {
+		log.Printf("error getting transaction %s: %s\n", request.TransactionID, err)
+		respondError(req.Context(), w, apiErrorFromError(err))
+		return
+	}
+
+	// updates are verified as of the time they were made on chain, like in the watcher
+	verificationTime, err := vh.client.GetTransactionTime(nodeCtx, request.TransactionID)
+	if err != nil {
+		log.Printf("error getting block of transaction %s: %s\n", request.TransactionID, err)
+		respondError(req.Context(), w, apiErrorFromError(err))
+		return
+	}
+
+	aleoSession, err := vh.pool.Get(req.Context())
+	if err != nil {
+		log.Println("error getting aleo session:", err)
+		respondError(req.Context(), w, NewApiError(ErrorServiceUnavailable, nil))
+		return
+	}
+	defer vh.pool.Put(aleoSession)
+
+	records := make([]*history.Record, 0, len(transitions))
+	defer func() {
+		recordHistory(req.Context(), vh.history, records...)
+	}()
+
+	response := &VerifyTransactionResponse{
+		Updates: make([]VerifiedUpdate, 0, len(transitions)),
+	}
+
+	// every update is verified and recorded, the transaction passes only if all of them pass
+	var firstErr error
+	for _, transition := range transitions {
+		update, record, err := vh.verifyTransition(req.Context(), aleoSession, transition, verificationTime)
+		records = append(records, record)
+
+		if err != nil {
+			log.Printf("error verifying transition %s of transaction %s: %s\n", transition.TransitionID, request.TransactionID, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("transition %s: %w", transition.TransitionID, err)
+			}
+			continue
+		}
+
+		response.Updates = append(response.Updates, *update)
+	}
+
+	if firstErr != nil {
+		respondError(req.Context(), w, apiErrorFromError(firstErr))
+		return
+	}
+
+	response.Success = true
+
+	respondVerifyTransaction(req.Context(), w, response)
+}
+
+// verifies one oracle update transition and returns its history record, which has the verdict
+func (vh *verifyTransactionHandler) verifyTransition(ctx context.Context, aleoSession aleo_wrapper.Session, transition *contract.OracleTransition, verificationTime time.Time) (*VerifiedUpdate, *history.Record, error) {
+	log := GetContextLogger(ctx)
+
+	record := &history.Record{
+		Source:        history.SOURCE_TRANSACTION,
+		TransactionID: transition.TransactionID,
+		TransitionID:  transition.TransitionID,
+		ReportType:    transition.ReportType,
+		ReferenceTime: verificationTime.UTC(),
+	}
+
+	if report, err := transition.RecoverReport(); err == nil {
+		record.ReportHash = history.HashReport(report)
+	}
+
+	verified, err := contract.VerifyOracleTransition(aleoSession, transition, vh.measurements.Sets(), vh.sgxPolicy, verificationTime)
+	if err != nil {
+		record.ErrorCode = errorCode(err)
+		record.ErrorMessage = err.Error()
+		return nil, record, err
+	}
+
+	record.Valid = true
+	record.Url = verified.DecodedData.Url
+	record.AttestationTimestamp = verified.DecodedData.Timestamp
+
+	update := &VerifiedUpdate{
+		Transition:  verified.Transition,
+		DecodedData: verified.DecodedData,
+		Hash: &ProofDataHash{
+			Hex:    hex.EncodeToString(verified.Encoded.Hash),
+			Base64: base64.StdEncoding.EncodeToString(verified.Encoded.Hash),
+			U128:   verified.Encoded.HashU128,
+		},
+	}
+
+	if verified.Report.Measurements != nil {
+		update.MatchedMeasurements = verified.Report.Measurements.Label
+		update.MeasurementsSource = verified.Report.Measurements.Source
+		record.MatchedMeasurements = update.MatchedMeasurements
+		record.MeasurementsSource = update.MeasurementsSource
+	}
+
+	update.Details, err = attestation.GetReportDetails(verified.Report.Report)
+	if err != nil {
+		log.Printf("failed to get %s report details: %s\n", verified.Transition.ReportType, err)
+	}
+
+	return update, record, nil
+}
+
+// CreateVerifyTransactionHandler creates a handler that verifies every oracle update in a transaction by its ID.
+// The transaction is retrieved from the Aleo node API, and only the update transitions of the configured contract are considered.
+// The updates are verified as of the time of the transaction's block.
+func CreateVerifyTransactionHandler(pool *sessionPool.Pool, measurements *attestation.MeasurementRegistry, sgxPolicy *sgx.Policy, client *contract.Client, contractName string, functions contract.UpdateFunctions, nodeTimeout time.Duration, historyStore history.Store) http.Handler {
+	return &verifyTransactionHandler{
+		pool:         pool,
+		measurements: measurements,
+		sgxPolicy:    sgxPolicy,
+		client:       client,
+		contractName: contractName,
+		functions:    functions,
+		nodeTimeout:  nodeTimeout,
+		history:      historyStore,
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+// a report response with the fake report bytes, see fakeVerifyReport
+func verifyTestReport(report string) attestation.AttestationResponse {
+	return attestation.AttestationResponse{
+		AttestationReport:  base64.StdEncoding.EncodeToString([]byte(report)),
+		ReportType:         attestation.TEE_TYPE_SGX,
+		AttestationData:    "42",
+		ResponseStatusCode: http.StatusOK,
+		Timestamp:          1701851063,
+		AttestationRequest: attestation.AttestationRequest{
+			Url:            "example.com",
+			RequestMethod:  http.MethodGet,
+			ResponseFormat: "json",
+			Selector:       "price",
+			EncodingOptions: encoding.EncodingOptions{
+				Value: encoding.ENCODING_OPTION_INT,
+			},
+		},
+	}
+}
+
+// verifies fake reports: "valid" commits to the response of verifyTestReport, "other data" commits to different data,
+// and anything else is rejected
+func fakeVerifyReport(t *testing.T) func(string, []byte, string, []attestation.MeasurementSet, *sgx.Policy, time.Time) (*attestation.VerifiedReport, error) {
+	resp := verifyTestReport("")
+	encoded, err := attestation.EncodeProofData(new(testutil.FakeSession), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func(reportType string, report []byte, nonce string, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*attestation.VerifiedReport, error) {
+		userData := make([]byte, 64)
+
+		switch string(report) {
+		case "valid":
+			copy(userData, encoded.Hash)
+		case "other data":
+		default:
+			return nil, fmt.Errorf("%w: bad signature", attestation.ErrReportInvalid)
+		}
+
+		return &attestation.VerifiedReport{UserData: userData}, nil
+	}
+}
+
+func TestVerifyHandlerVerdicts(t *testing.T) {
+	notBase64 := verifyTestReport("")
+	notBase64.AttestationReport = "not base64"
+
+	tests := []struct {
+		name    string
+		reports []attestation.AttestationResponse
+		// only checked if the status is OK
+		wantValid []int
+		// error code of every report, empty for valid reports
+		wantCodes   []string
+		wantStatus  int
+		wantSuccess bool
+	}{
+		{
+			name:        "all valid",
+			reports:     []attestation.AttestationResponse{verifyTestReport("valid"), verifyTestReport("valid")},
+			wantValid:   []int{0, 1},
+			wantCodes:   []string{"", ""},
+			wantStatus:  http.StatusOK,
+			wantSuccess: true,
+		},
+		{
+			name: "mixed valid and invalid",
+			reports: []attestation.AttestationResponse{
+				verifyTestReport("valid"),
+				notBase64,
+				verifyTestReport("rejected"),
+				verifyTestReport("other data"),
+				verifyTestReport("valid"),
+			},
+			wantValid:  []int{0, 4},
+			wantCodes:  []string{"", VerifyErrorInvalidReportEncoding, VerifyErrorReportInvalid, VerifyErrorUserDataHashMismatch, ""},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "every report invalid",
+			reports:    []attestation.AttestationResponse{notBase64, verifyTestReport("rejected"), verifyTestReport("other data")},
+			wantValid:  []int{},
+			wantCodes:  []string{VerifyErrorInvalidReportEncoding, VerifyErrorReportInvalid, VerifyErrorUserDataHashMismatch},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "empty request",
+			reports:    []attestation.AttestationResponse{},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			handler := CreateVerifyHandler(pool, nil, nil, nil, nil, false, 2, 0, 10*time.Millisecond, history.NewMemoryStore(history.Retention{}))
+			handler.(*verifyHandler).verify = fakeVerifyReport(t)
+
+			body, err := json.Marshal(&VerifyReportsRequest{Reports: tt.reports})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				response := new(ErrorResponse)
+				if err = json.Unmarshal(w.Body.Bytes(), response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Success || response.Error == nil || response.Error.Code != ErrorInvalidRequest {
+					t.Errorf("unexpected error response %s", w.Body.String())
+				}
+				return
+			}
+
+			response := new(VerifyReportsResponse)
+			if err = json.Unmarshal(w.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Success != tt.wantSuccess {
+				t.Errorf("success = %v, want %v", response.Success, tt.wantSuccess)
+			}
+			if tt.wantSuccess != (response.ErrorMessage == "") {
+				t.Errorf("unexpected error message %q", response.ErrorMessage)
+			}
+
+			if fmt.Sprint(response.ValidReports) != fmt.Sprint(tt.wantValid) || response.ValidReports == nil {
+				t.Errorf("valid reports = %v, want %v", response.ValidReports, tt.wantValid)
+			}
+
+			if len(response.Results) != len(tt.wantCodes) {
+				t.Fatalf("got %d results, want %d", len(response.Results), len(tt.wantCodes))
+			}
+			for idx, result := range response.Results {
+				if result.Index != idx || result.Valid != (tt.wantCodes[idx] == "") || result.ErrorCode != tt.wantCodes[idx] {
+					t.Errorf("result %d = %+v, want error code %q", idx, result, tt.wantCodes[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyHandlerAsOf(t *testing.T) {
+	const body = `{"reports": [{"reportType": "sgx", "attestationReport": "not base64"}], "asOf": "2024-09-09T08:00:00Z"}`
+
+	tests := []struct {
+		name       string
+		freshness  *attestation.FreshnessPolicy
+		wantStatus int
+	}{
+		{
+			name:       "no freshness policy",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not allowed",
+			freshness:  &attestation.FreshnessPolicy{MaxAttestationAge: 60},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "allowed",
+			freshness:  &attestation.FreshnessPolicy{AllowAsOf: true},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			handler := CreateVerifyHandler(pool, nil, nil, tt.freshness, nil, false, 1, 0, 10*time.Millisecond, history.NewMemoryStore(history.Retention{}))
+
+			req := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyHandlerSessions(t *testing.T) {
+	const body = `{"reports": [
+		{"reportType": "sgx", "attestationReport": "not base64"},
+		{"reportType": "sgx", "attestationReport": "not base64"},
+		{"reportType": "sgx", "attestationReport": "not base64"}
+	]}`
+
+	tests := []struct {
+		name       string
+		maxReports int
+		// number of sessions borrowed by someone else during the request
+		borrowed   int
+		wantStatus int
+	}{
+		{
+			name:       "all sessions available",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "busy pool",
+			borrowed:   1,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "no sessions",
+			borrowed:   2,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "too many reports",
+			maxReports: 2,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			borrowed := make([]aleo_wrapper.Session, 0, tt.borrowed)
+			for i := 0; i < tt.borrowed; i++ {
+				session, err := pool.Get(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				borrowed = append(borrowed, session)
+			}
+			defer func() {
+				for _, session := range borrowed {
+					pool.Put(session)
+				}
+			}()
+
+			handler := CreateVerifyHandler(pool, nil, nil, nil, nil, false, 2, tt.maxReports, 10*time.Millisecond, history.NewMemoryStore(history.Retention{}))
+
+			req := httptest.NewRequest(http.MethodPost, "/verify", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			response := new(VerifyReportsResponse)
+			if err = json.Unmarshal(w.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(response.Results) != 3 {
+				t.Errorf("got %d results, want 3", len(response.Results))
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package attestation
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/zkportal/oracle-verification-backend/attestation/nitro"
 	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/u128"
 
 	encoding "github.com/zkportal/aleo-oracle-encoding"
 	aleo_wrapper "github.com/zkportal/aleo-utils-go"
@@ -57,64 +60,161 @@ var (
 	ErrVerificationFailedToHash      = errors.New("verification error: failed to hash message for report verification")
 	ErrVerificationFailedToMatchData = errors.New("verification error: userData hashes don't match")
 	ErrUnsupportedReportType         = errors.New("unsupported report type")
+	ErrReportInvalid                 = errors.New("report is invalid")
 )
 
-func VerifyReport(reportType string, report []byte, nonce string, targetUniqueId string, targetPcrValues [3]string) (interface{}, []byte, error) {
+// VerifiedReport is a report that passed verification
+type VerifiedReport struct {
+	// *attestation.Report from EGo for SGX, or *nitrite.Document for Nitro
+	Report   interface{}
+	UserData []byte
+	// the measurement set that the report matched, nil if an SGX report was matched by signer
+	Measurements *MeasurementSet
+	// the nonce in a Nitro attestation document, nil for SGX
+	Nonce []byte
+}
+
+// VerifyReport verifies a report and matches it to one of the measurement sets that are valid at verificationTime
+func VerifyReport(reportType string, report []byte, nonce string, measurements []MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*VerifiedReport, error) {
+	validMeasurements := validMeasurementsAt(measurements, verificationTime)
+
 	switch reportType {
 	case TEE_TYPE_SGX:
-		parsedReport, err := sgx.VerifySgxReport(report, targetUniqueId)
+		candidates := make([]*MeasurementSet, 0, len(validMeasurements))
+		targetUniqueIds := make([]string, 0, len(validMeasurements))
+		for _, m := range validMeasurements {
+			if m.HasSgx() {
+				candidates = append(candidates, m)
+				targetUniqueIds = append(targetUniqueIds, m.UniqueID)
+			}
+		}
+
+		parsedReport, targetIdx, err := sgx.VerifySgxReport(report, targetUniqueIds, sgxPolicy)
 		if err != nil {
-			return nil, nil, err
+			return nil, fmt.Errorf("%w: %w", ErrReportInvalid, err)
+		}
+
+		result := &VerifiedReport{
+			Report:   parsedReport,
+			UserData: parsedReport.Data,
+		}
+		if targetIdx != -1 {
+			result.Measurements = candidates[targetIdx]
 		}
 
-		return parsedReport, parsedReport.Data, nil
+		return result, nil
 
 	case TEE_TYPE_NITRO:
-		parsedReport, err := nitro.VerifyNitroReport(report, nonce, targetPcrValues)
+		candidates := make([]*MeasurementSet, 0, len(validMeasurements))
+		targetPcrValues := make([][3]string, 0, len(validMeasurements))
+		for _, m := range validMeasurements {
+			if m.HasNitro() {
+				candidates = append(candidates, m)
+				targetPcrValues = append(targetPcrValues, [3]string(m.PcrValues))
+			}
+		}
+
+		parsedReport, targetIdx, err := nitro.VerifyNitroReport(report, nonce, targetPcrValues)
 		if err != nil {
-			return nil, nil, err
+			return nil, fmt.Errorf("%w: %w", ErrReportInvalid, err)
 		}
 
-		return parsedReport, parsedReport.UserData, nil
+		return &VerifiedReport{
+			Report:       parsedReport,
+			UserData:     parsedReport.UserData,
+			Measurements: candidates[targetIdx],
+			Nonce:        parsedReport.Nonce,
+		}, nil
 
 	default:
-		return nil, nil, ErrUnsupportedReportType
+		return nil, ErrUnsupportedReportType
 	}
 }
 
-func VerifyReportData(aleoSession aleo_wrapper.Session, userData []byte, resp *AttestationResponse) error {
+// EncodedProofData is the data that an enclave commits to in the report's user data
+type EncodedProofData struct {
+	// encoded attestation response with the price feed tag applied
+	ProofData []byte `json:"proofData"`
+	// ReportData Leo struct with the proof data
+	LeoStruct string `json:"leoStruct"`
+	// Poseidon8 hash of the Leo struct
+	Hash []byte `json:"hash"`
+	// Poseidon8 hash as a Leo u128 literal
+	HashU128 string `json:"hashU128"`
+	// tag written to the first byte of the proof data for price feeds, 0 for other requests
+	PriceFeedTag uint8 `json:"priceFeedTag"`
+}
+
+// EncodeProofData computes the proof data, the Leo struct and the hash that an enclave commits to
+// when it attests the response
+func EncodeProofData(aleoSession aleo_wrapper.Session, resp *AttestationResponse) (*EncodedProofData, error) {
 	dataBytes, err := PrepareProofData(resp.ResponseStatusCode, resp.AttestationData, resp.Timestamp, &resp.AttestationRequest)
 	if err != nil {
-		log.Printf("prepareProofData: %v", err)
-		return ErrVerificationFailedToPrepare
+		return nil, fmt.Errorf("%w: %w", ErrVerificationFailedToPrepare, err)
+	}
+
+	var tag uint8
+	if feed := GetPriceFeed(resp.AttestationRequest.Url); feed != nil {
+		tag = feed.Tag
+		dataBytes[0] = tag
 	}
 
-	if resp.AttestationRequest.Url == PriceFeedAleoUrl {
-		dataBytes[0] = 8
-	} else if resp.AttestationRequest.Url == PriceFeedBtcUrl {
-		dataBytes[0] = 12
-	} else if resp.AttestationRequest.Url == PriceFeedEthUrl {
-		dataBytes[0] = 11
+	encoded, err := HashProofData(aleoSession, dataBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	formattedData, err := aleoSession.FormatMessage(dataBytes, ALEO_STRUCT_REPORT_DATA_SIZE)
+	encoded.PriceFeedTag = tag
+
+	return encoded, nil
+}
+
+// HashProofData formats the proof data as a ReportData Leo struct and computes its Poseidon8 hash,
+// which is what the enclave puts in the report's userData
+func HashProofData(aleoSession aleo_wrapper.Session, proofData []byte) (*EncodedProofData, error) {
+	formattedData, err := aleoSession.FormatMessage(proofData, ALEO_STRUCT_REPORT_DATA_SIZE)
 	if err != nil {
 		log.Printf("aleo.FormatMessage(): %v\n", err)
-		return ErrVerificationFailedToFormat
+		return nil, ErrVerificationFailedToFormat
 	}
 
 	attestationHash, err := aleoSession.HashMessage(formattedData)
 	if err != nil {
 		log.Printf("aleo.HashMessage(): %v\n", err)
-		return ErrVerificationFailedToHash
+		return nil, ErrVerificationFailedToHash
 	}
 
+	hashNumber, err := u128.SliceToU128(attestationHash)
+	if err != nil {
+		log.Printf("u128.SliceToU128(): %v\n", err)
+		return nil, ErrVerificationFailedToHash
+	}
+
+	return &EncodedProofData{
+		ProofData: proofData,
+		LeoStruct: string(formattedData),
+		Hash:      attestationHash,
+		HashU128:  hashNumber.String() + "u128",
+	}, nil
+}
+
+// MatchUserData checks that the report's userData starts with the proof data hash
+func MatchUserData(hash []byte, userData []byte) error {
 	// Poseidon8 hash is 16 bytes when represented in bytes so here we compare
 	// the resulting hash only with 16 out of 64 bytes of the report's user data.
 	// IMPORTANT! this needs to be adjusted if we put more data in the report
-	if !bytes.Equal(attestationHash, userData[:16]) {
+	if len(userData) < 16 || !bytes.Equal(hash, userData[:16]) {
 		return ErrVerificationFailedToMatchData
 	}
 
 	return nil
 }
+
+func VerifyReportData(aleoSession aleo_wrapper.Session, userData []byte, resp *AttestationResponse) error {
+	encoded, err := EncodeProofData(aleoSession, resp)
+	if err != nil {
+		return err
+	}
+
+	return MatchUserData(encoded.Hash, userData)
+}
@@ -0,0 +1,169 @@
+package attestation
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+func TestEncodeProofData(t *testing.T) {
+	response := func(url string) *AttestationResponse {
+		return &AttestationResponse{
+			AttestationData:    "42",
+			ResponseStatusCode: http.StatusOK,
+			Timestamp:          1701851063,
+			AttestationRequest: AttestationRequest{
+				Url:            url,
+				RequestMethod:  http.MethodGet,
+				ResponseFormat: "json",
+				Selector:       "price",
+				EncodingOptions: encoding.EncodingOptions{
+					Value: encoding.ENCODING_OPTION_INT,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantTag uint8
+	}{
+		{
+			name: "not a price feed",
+			url:  "example.com",
+		},
+		{
+			name:    "aleo price feed",
+			url:     PriceFeedAleoUrl,
+			wantTag: 8,
+		},
+		{
+			name:    "btc price feed",
+			url:     PriceFeedBtcUrl,
+			wantTag: 12,
+		},
+		{
+			name:    "eth price feed",
+			url:     PriceFeedEthUrl,
+			wantTag: 11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := new(testutil.FakeSession)
+			resp := response(tt.url)
+
+			encoded, err := EncodeProofData(session, resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if encoded.PriceFeedTag != tt.wantTag {
+				t.Errorf("expected tag %d, got %d", tt.wantTag, encoded.PriceFeedTag)
+			}
+
+			if tt.wantTag != 0 && encoded.ProofData[0] != tt.wantTag {
+				t.Errorf("expected the first proof data byte to be %d, got %d", tt.wantTag, encoded.ProofData[0])
+			}
+
+			// the fake session formats the proof data as it is
+			if encoded.LeoStruct != string(encoded.ProofData) {
+				t.Errorf("unexpected Leo struct %s", encoded.LeoStruct)
+			}
+
+			if !bytes.Equal(encoded.Hash, encoded.ProofData[:16]) {
+				t.Errorf("unexpected hash %v", encoded.Hash)
+			}
+
+			if len(encoded.HashU128) < 5 || encoded.HashU128[len(encoded.HashU128)-4:] != "u128" {
+				t.Errorf("unexpected u128 hash %s", encoded.HashU128)
+			}
+
+			userData := make([]byte, 64)
+			copy(userData, encoded.Hash)
+			if err = VerifyReportData(session, userData, resp); err != nil {
+				t.Errorf("expected report data to match, got %v", err)
+			}
+
+			userData[0]++
+			if err = VerifyReportData(session, userData, resp); !errors.Is(err, ErrVerificationFailedToMatchData) {
+				t.Errorf("expected %v, got %v", ErrVerificationFailedToMatchData, err)
+			}
+		})
+	}
+}
+
+func TestEncodeProofDataInvalidRequest(t *testing.T) {
+	resp := &AttestationResponse{
+		AttestationData:    "42",
+		ResponseStatusCode: http.StatusOK,
+		Timestamp:          1701851063,
+		AttestationRequest: AttestationRequest{
+			Url:            "example.com",
+			RequestMethod:  http.MethodGet,
+			ResponseFormat: "json",
+			Selector:       "price",
+			EncodingOptions: encoding.EncodingOptions{
+				Value: "not an encoding",
+			},
+		},
+	}
+
+	_, err := EncodeProofData(new(testutil.FakeSession), resp)
+	if !errors.Is(err, ErrVerificationFailedToPrepare) {
+		t.Fatalf("expected %v, got %v", ErrVerificationFailedToPrepare, err)
+	}
+
+	// the error tells the caller what is wrong with the request
+	if err.Error() == ErrVerificationFailedToPrepare.Error() {
+		t.Errorf("expected the cause in the error, got %v", err)
+	}
+}
+
+func TestMatchUserData(t *testing.T) {
+	hash := bytes.Repeat([]byte{7}, 16)
+
+	tests := []struct {
+		name     string
+		userData []byte
+		wantErr  bool
+	}{
+		{
+			name:     "matching prefix",
+			userData: append(bytes.Repeat([]byte{7}, 16), make([]byte, 48)...),
+		},
+		{
+			name:     "different prefix",
+			userData: make([]byte, 64),
+			wantErr:  true,
+		},
+		{
+			name:     "short userData",
+			userData: []byte{7, 7, 7},
+			wantErr:  true,
+		},
+		{
+			name:    "no userData",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MatchUserData(hash, tt.userData)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MatchUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrVerificationFailedToMatchData) {
+				t.Errorf("MatchUserData() error = %v, want %v", err, ErrVerificationFailedToMatchData)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// length of the Poseidon8 hash of the attested data at the start of the report's user data
+const userDataHashLength = 16
+
+var (
+	ErrSingleTeeGroup               = errors.New("group doesn't have reports from more than one TEE type")
+	ErrGroupAttestationDataMismatch = errors.New("reports in the group attest to different data")
+	ErrGroupTimestampMismatch       = errors.New("reports in the group have different timestamps")
+	ErrGroupUserDataMismatch        = errors.New("reports in the group have different userData hashes")
+)
+
+// ConsistencyMember is a verified report in a consistency group
+type ConsistencyMember struct {
+	Response *AttestationResponse
+	// user data of the verified report
+	UserData []byte
+}
+
+// GroupReports groups report indexes by their attestation request. The groups and the indexes in them
+// are in the order in which they first appear in reports.
+func GroupReports(reports []AttestationResponse) ([][]int, error) {
+	groupIdx := make(map[string]int)
+	var groups [][]int
+
+	for idx := range reports {
+		// encoding/json sorts map keys, so equal requests have equal keys
+		key, err := json.Marshal(&reports[idx].AttestationRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to group report %d: %w", idx, err)
+		}
+
+		gIdx, ok := groupIdx[string(key)]
+		if !ok {
+			gIdx = len(groups)
+			groupIdx[string(key)] = gIdx
+			groups = append(groups, nil)
+		}
+
+		groups[gIdx] = append(groups[gIdx], idx)
+	}
+
+	return groups, nil
+}
+
+func userDataHash(userData []byte) []byte {
+	if len(userData) < userDataHashLength {
+		return userData
+	}
+
+	return userData[:userDataHashLength]
+}
+
+// CheckGroupConsistency checks that verified reports for the same attestation request attest to the same
+// data, timestamp and hashed user data, and that they come from at least two different TEE types.
+func CheckGroupConsistency(members []ConsistencyMember) error {
+	if len(members) == 0 {
+		return ErrSingleTeeGroup
+	}
+
+	first := members[0]
+	multiTee := false
+
+	for _, m := range members[1:] {
+		if m.Response.AttestationData != first.Response.AttestationData {
+			return fmt.Errorf("%w: %q and %q", ErrGroupAttestationDataMismatch, first.Response.AttestationData, m.Response.AttestationData)
+		}
+
+		if m.Response.Timestamp != first.Response.Timestamp {
+			return fmt.Errorf("%w: %d and %d", ErrGroupTimestampMismatch, first.Response.Timestamp, m.Response.Timestamp)
+		}
+
+		if !bytes.Equal(userDataHash(m.UserData), userDataHash(first.UserData)) {
+			return ErrGroupUserDataMismatch
+		}
+
+		if m.Response.ReportType != first.Response.ReportType {
+			multiTee = true
+		}
+	}
+
+	if !multiTee {
+		return ErrSingleTeeGroup
+	}
+
+	return nil
+}
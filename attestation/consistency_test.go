@@ -0,0 +1,121 @@
+package attestation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGroupReports(t *testing.T) {
+	reqA := AttestationRequest{Url: "a.example", RequestMethod: "GET", RequestHeaders: map[string]string{"A": "1", "B": "2"}}
+	reqA2 := AttestationRequest{Url: "a.example", RequestMethod: "GET", RequestHeaders: map[string]string{"B": "2", "A": "1"}}
+	reqB := AttestationRequest{Url: "b.example", RequestMethod: "GET"}
+
+	reports := []AttestationResponse{
+		{ReportType: TEE_TYPE_SGX, AttestationRequest: reqA},
+		{ReportType: TEE_TYPE_SGX, AttestationRequest: reqB},
+		{ReportType: TEE_TYPE_NITRO, AttestationRequest: reqA2},
+		{ReportType: TEE_TYPE_NITRO, AttestationRequest: reqB},
+		{ReportType: TEE_TYPE_NITRO, AttestationRequest: AttestationRequest{Url: "c.example"}},
+	}
+
+	groups, err := GroupReports(reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]int{{0, 2}, {1, 3}, {4}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("expected groups %v, got %v", expected, groups)
+	}
+}
+
+func TestCheckGroupConsistency(t *testing.T) {
+	userData := make([]byte, 64)
+	userData[0] = 1
+
+	otherUserData := make([]byte, 64)
+	otherUserData[0] = 2
+
+	// only the hash at the start of userData is compared
+	sameHashUserData := make([]byte, 64)
+	sameHashUserData[0] = 1
+	sameHashUserData[63] = 1
+
+	member := func(reportType, data string, timestamp int64, userData []byte) ConsistencyMember {
+		return ConsistencyMember{
+			Response: &AttestationResponse{
+				ReportType:      reportType,
+				AttestationData: data,
+				Timestamp:       timestamp,
+			},
+			UserData: userData,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		members []ConsistencyMember
+		wantErr error
+	}{
+		{
+			name: "consistent",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_SGX, "42", 100, userData),
+				member(TEE_TYPE_NITRO, "42", 100, sameHashUserData),
+			},
+		},
+		{
+			name:    "empty group",
+			wantErr: ErrSingleTeeGroup,
+		},
+		{
+			name: "single report",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_SGX, "42", 100, userData),
+			},
+			wantErr: ErrSingleTeeGroup,
+		},
+		{
+			name: "same TEE type",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_NITRO, "42", 100, userData),
+				member(TEE_TYPE_NITRO, "42", 100, userData),
+			},
+			wantErr: ErrSingleTeeGroup,
+		},
+		{
+			name: "different data",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_SGX, "42", 100, userData),
+				member(TEE_TYPE_NITRO, "43", 100, userData),
+			},
+			wantErr: ErrGroupAttestationDataMismatch,
+		},
+		{
+			name: "different timestamp",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_SGX, "42", 100, userData),
+				member(TEE_TYPE_NITRO, "42", 101, userData),
+			},
+			wantErr: ErrGroupTimestampMismatch,
+		},
+		{
+			name: "different userData",
+			members: []ConsistencyMember{
+				member(TEE_TYPE_SGX, "42", 100, userData),
+				member(TEE_TYPE_NITRO, "42", 100, otherUserData),
+			},
+			wantErr: ErrGroupUserDataMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckGroupConsistency(tt.members)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
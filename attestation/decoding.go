@@ -1,7 +1,9 @@
 package attestation
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -14,6 +16,12 @@ type DecodedProofData struct {
 	AttestationData    string `json:"attestationData"`
 	ResponseStatusCode int    `json:"responseStatusCode"`
 	Timestamp          int64  `json:"timestamp"`
+
+	// attestation data as an exact typed value, use it instead of AttestationData for large numbers
+	AttestationValue *AttestationValue `json:"attestationValue"`
+
+	// the registered price feed that the proof data belongs to, nil for other requests
+	PriceFeed *PriceFeed `json:"priceFeed,omitempty"`
 }
 
 // returns a number aligned to a full block
@@ -25,15 +33,96 @@ func alignToBlock(num int) int {
 	return num + (encoding.TARGET_ALIGNMENT - (num % encoding.TARGET_ALIGNMENT))
 }
 
-// returns a slice from buf starting in position pos and taking at least length bytes. The length will be aligned to a full block
-func getBlockSlice(buf []byte, pos, length int) ([]byte, int, error) {
+// lengths of the fixed-size fields in the meta header
+const (
+	encodedTimestampLen       = 8
+	encodedStatusCodeLen      = 8
+	encodedResponseFormatLen  = 1
+	encodedEncodingOptionsLen = 16
+)
+
+var (
+	ErrProofDataTooShort     = errors.New("too short to be encoded proof data")
+	ErrFieldOutOfBounds      = errors.New("field extends past the end of the proof data")
+	ErrUnexpectedFieldLength = errors.New("unexpected field length in the meta header")
+	ErrMalformedField        = errors.New("malformed field")
+
+	ErrUnsupportedPriceFeedEncoding = errors.New("price feed attestation data must be encoded as a number")
+)
+
+// DecodeError describes which field of the proof data could not be decoded
+type DecodeError struct {
+	Field string
+	// byte offset of the field in the proof data
+	Offset      int
+	ExpectedLen int
+	ActualLen   int
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode %s at offset %d (expected %d bytes, got %d): %v", e.Field, e.Offset, e.ExpectedLen, e.ActualLen, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// reads block-aligned fields from the proof data and remembers the last field for errors
+type proofDataDecoder struct {
+	buf []byte
+	pos int
+
+	field    string
+	fieldPos int
+	fieldLen int
+}
+
+// returns the next field, taking at least length bytes. The length will be aligned to a full block
+func (d *proofDataDecoder) next(field string, length int) ([]byte, error) {
 	blockAlignedLen := alignToBlock(length)
 
-	if pos+blockAlignedLen > len(buf) {
-		return nil, -1, errors.New("invalid position for buffer")
+	d.field = field
+	d.fieldPos = d.pos
+	d.fieldLen = blockAlignedLen
+
+	if d.pos+blockAlignedLen > len(d.buf) {
+		return nil, d.error(ErrFieldOutOfBounds, blockAlignedLen, len(d.buf)-d.pos)
 	}
 
-	return buf[pos : pos+blockAlignedLen], blockAlignedLen, nil
+	// limit the capacity so that decoding a field can never read the following fields
+	block := d.buf[d.pos : d.pos+blockAlignedLen : d.pos+blockAlignedLen]
+	d.pos += blockAlignedLen
+
+	return block, nil
+}
+
+// checks the length of a fixed-size field in the meta header
+func (d *proofDataDecoder) checkLength(field string, expected, actual int) error {
+	if expected == actual {
+		return nil
+	}
+
+	d.field = field
+	d.fieldPos = d.pos
+
+	return d.error(ErrUnexpectedFieldLength, expected, actual)
+}
+
+// wraps err in a DecodeError for the current field
+func (d *proofDataDecoder) error(err error, expectedLen, actualLen int) error {
+	return &DecodeError{
+		Field:       d.field,
+		Offset:      d.fieldPos,
+		ExpectedLen: expectedLen,
+		ActualLen:   actualLen,
+		Err:         err,
+	}
+}
+
+// wraps an error from decoding the content of the current field
+func (d *proofDataDecoder) malformed(err error) error {
+	return d.error(fmt.Errorf("%w: %w", ErrMalformedField, err), d.fieldLen, d.fieldLen)
 }
 
 func cleanupDecodedFloat(data string, precision uint) string {
@@ -46,21 +135,123 @@ func cleanupDecodedFloat(data string, precision uint) string {
 	return formatted
 }
 
+// returns the registered price feed if the proof data is from one. The enclave overwrites the first byte
+// of the meta header with the feed's tag, so the attestation data length cannot be used to find the URL.
+// Price feed data is a number, which is always encoded as 1 block
+func detectPriceFeed(buf []byte, header *encoding.MetaHeader) *PriceFeed {
+	urlPos := encoding.TARGET_ALIGNMENT*2 + encoding.TARGET_ALIGNMENT + alignToBlock(header.TimestampLen) + alignToBlock(header.StatusCodeLen)
+	if urlPos+header.UrlLen > len(buf) {
+		return nil
+	}
+
+	feed := GetPriceFeed(string(buf[urlPos : urlPos+header.UrlLen]))
+	if feed == nil || feed.Tag != buf[0] {
+		return nil
+	}
+
+	return feed
+}
+
+// decodes the optional fields block in the format of encoding.DecodeOptionalFields. The encoding library doesn't check
+// the encoded lengths against the remaining bytes and can panic, so every length is checked here before it's used
+func decodeOptionalFields(buf []byte) (htmlResultType, requestContentType, requestBody *string, err error) {
+	if len(buf) < 4*encoding.TARGET_ALIGNMENT || len(buf)%encoding.TARGET_ALIGNMENT != 0 {
+		return nil, nil, nil, encoding.ErrDecodingBufferTooShort
+	}
+
+	// the first byte of the header is a bitmask of the present fields, the last 8 bytes are the number of the following blocks
+	header := buf[:encoding.TARGET_ALIGNMENT]
+	blockCount := binary.LittleEndian.Uint64(header[encoding.TARGET_ALIGNMENT/2:])
+	if blockCount != uint64(len(buf)/encoding.TARGET_ALIGNMENT-1) {
+		return nil, nil, nil, encoding.ErrDecodingOptionalsCountLengthMismatch
+	}
+
+	// no optional fields are present
+	if header[0] == 0 {
+		return nil, nil, nil, nil
+	}
+
+	pos := encoding.TARGET_ALIGNMENT
+
+	// HTML result type is in the first byte of its block
+	if header[0]&encoding.OPTIONAL_FIELDS_HEADER_HAS_HTML_RESULT_TYPE != 0 {
+		switch buf[pos] {
+		case encoding.HTML_RESULT_TYPE_ELEMENT_VALUE:
+			htmlResultType = new(string)
+			*htmlResultType = encoding.HTML_RESULT_TYPE_ELEMENT
+		case encoding.HTML_RESULT_TYPE_VALUE_VALUE:
+			htmlResultType = new(string)
+			*htmlResultType = encoding.HTML_RESULT_TYPE_VALUE
+		default:
+			return nil, nil, nil, encoding.ErrHtmlResultTypeUnknown
+		}
+	}
+	pos += encoding.TARGET_ALIGNMENT
+
+	// reads a string field, which is a block with the string length in the first 8 bytes followed by the padded string.
+	// A missing field is one block of zeros
+	readString := func(present bool, errLength error) (*string, error) {
+		if pos+encoding.TARGET_ALIGNMENT > len(buf) {
+			return nil, errLength
+		}
+
+		if !present {
+			pos += encoding.TARGET_ALIGNMENT
+			return nil, nil
+		}
+
+		length := binary.LittleEndian.Uint64(buf[pos : pos+encoding.TARGET_ALIGNMENT/2])
+		start := pos + encoding.TARGET_ALIGNMENT
+		if length > uint64(len(buf)-start) {
+			return nil, errLength
+		}
+
+		value := string(buf[start : start+int(length)])
+		pos = start + alignToBlock(int(length))
+
+		return &value, nil
+	}
+
+	requestContentType, err = readString(header[0]&encoding.OPTIONAL_FIELDS_HEADER_HAS_CONTENT_TYPE != 0, encoding.ErrDecodingOptionalsInvalidContentTypeLength)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	requestBody, err = readString(header[0]&encoding.OPTIONAL_FIELDS_HEADER_HAS_REQUEST_BODY != 0, encoding.ErrDecodingOptionalsInvalidBodyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if pos != len(buf) {
+		return nil, nil, nil, encoding.ErrDecodingOptionalsInvalidEncoding
+	}
+
+	return htmlResultType, requestContentType, requestBody, nil
+}
+
+// DecodeProofData decodes the proof data that an enclave commits to. It never panics on malformed input,
+// every decoding failure is a *DecodeError
 func DecodeProofData(buf []byte) (*DecodedProofData, error) {
+	d := &proofDataDecoder{
+		buf:      buf,
+		field:    "meta header",
+		fieldLen: encoding.TARGET_ALIGNMENT * 2,
+	}
+
 	if len(buf) < encoding.TARGET_ALIGNMENT*2 {
 		// the buffer doesn't even have a meta header, no need to try to parse anything
-		return nil, errors.New("too short to be encoded proof data")
+		return nil, d.error(ErrProofDataTooShort, encoding.TARGET_ALIGNMENT*2, len(buf))
 	}
 
-	// byte position for processing
-	pos := 0
-
-	header, err := encoding.DecodeMetaHeader(buf[:encoding.TARGET_ALIGNMENT*2])
+	metaHeaderBytes, err := d.next("meta header", encoding.TARGET_ALIGNMENT*2)
 	if err != nil {
 		return nil, err
 	}
 
-	pos += encoding.TARGET_ALIGNMENT * 2
+	header, err := encoding.DecodeMetaHeader(metaHeaderBytes)
+	if err != nil {
+		return nil, d.malformed(err)
+	}
 
 	// int and float use the length of 255 in the header, they are always encoded as 1 block.
 	// otherwise it's a string encoded as 256 blocks
@@ -69,83 +260,108 @@ func DecodeProofData(buf []byte) (*DecodedProofData, error) {
 		attestationDataLen = encoding.TARGET_ALIGNMENT
 	}
 
+	// length of the original attestation data string, which is used for restoring float precision
+	attestationDataStringLen := attestationDataLen
+
+	priceFeed := detectPriceFeed(buf, header)
+	if priceFeed != nil {
+		// the length in the header is overwritten with the tag
+		attestationDataLen = encoding.TARGET_ALIGNMENT
+		attestationDataStringLen = 0
+	}
+
 	// get attestation data bytes, parse them later
-	attestationDataBytes, posChange, err := getBlockSlice(buf, pos, attestationDataLen)
+	attestationDataBytes, err := d.next("attestation data", attestationDataLen)
 	if err != nil {
 		return nil, err
 	}
-	pos += posChange
+	attestationDataPos := d.fieldPos
 
 	// decode timestamp
-	timestampBytes, posChange, err := getBlockSlice(buf, pos, header.TimestampLen)
+	if err = d.checkLength("timestamp", encodedTimestampLen, header.TimestampLen); err != nil {
+		return nil, err
+	}
+	timestampBytes, err := d.next("timestamp", header.TimestampLen)
 	if err != nil {
 		return nil, err
 	}
-	timestamp := encoding.BytesToNumber(timestampBytes[:encoding.TARGET_ALIGNMENT/2])
-	pos += posChange
+	timestamp := encoding.BytesToNumber(timestampBytes[:encodedTimestampLen])
 
 	// decode status code
-	statusCodeBytes, posChange, err := getBlockSlice(buf, pos, header.StatusCodeLen)
+	if err = d.checkLength("status code", encodedStatusCodeLen, header.StatusCodeLen); err != nil {
+		return nil, err
+	}
+	statusCodeBytes, err := d.next("status code", header.StatusCodeLen)
 	if err != nil {
 		return nil, err
 	}
-	statusCode := encoding.BytesToNumber(statusCodeBytes[:encoding.TARGET_ALIGNMENT/2])
-	pos += posChange
+	statusCode := encoding.BytesToNumber(statusCodeBytes[:encodedStatusCodeLen])
 
 	// decode URL
-	urlBytes, posChange, err := getBlockSlice(buf, pos, header.UrlLen)
+	urlBytes, err := d.next("url", header.UrlLen)
 	if err != nil {
 		return nil, err
 	}
 	// we may have some zero bytes as padding - remove them
 	url := string(urlBytes[:header.UrlLen])
-	pos += posChange
 
 	// decode selector
-	selectorBytes, posChange, err := getBlockSlice(buf, pos, header.SelectorLen)
+	selectorBytes, err := d.next("selector", header.SelectorLen)
 	if err != nil {
 		return nil, err
 	}
 	// we may have some zero bytes as padding - remove them
 	selector := string(selectorBytes[:header.SelectorLen])
-	pos += posChange
 
 	// decode response format
-	responseFormatBytes, posChange, err := getBlockSlice(buf, pos, header.ResponseFormatLen)
+	if err = d.checkLength("response format", encodedResponseFormatLen, header.ResponseFormatLen); err != nil {
+		return nil, err
+	}
+	responseFormatBytes, err := d.next("response format", header.ResponseFormatLen)
 	if err != nil {
 		return nil, err
 	}
 	responseFormat, err := encoding.DecodeResponseFormat(responseFormatBytes)
 	if err != nil {
-		return nil, err
+		return nil, d.malformed(err)
 	}
-	pos += posChange
 
 	// decode request method
-	methodBytes, posChange, err := getBlockSlice(buf, pos, header.MethodLen)
+	methodBytes, err := d.next("request method", header.MethodLen)
 	if err != nil {
 		return nil, err
 	}
 	// we may have some zero bytes as padding - remove them
 	requestMethod := string(methodBytes[:header.MethodLen])
-	pos += posChange
 
 	// decode encoding options
-	encodingOptionsBytes, posChange, err := getBlockSlice(buf, pos, header.EncodingOptionsLen)
+	if err = d.checkLength("encoding options", encodedEncodingOptionsLen, header.EncodingOptionsLen); err != nil {
+		return nil, err
+	}
+	encodingOptionsBytes, err := d.next("encoding options", header.EncodingOptionsLen)
 	if err != nil {
 		return nil, err
 	}
 	encodingOptions, err := encoding.DecodeEncodingOptions(encodingOptionsBytes)
 	if err != nil {
-		return nil, err
+		return nil, d.malformed(err)
+	}
+	// the library divides by 10^precision, which overflows for precisions that the encoder never produces
+	if encodingOptions.Precision > encoding.ENCODING_OPTION_FLOAT_MAX_PRECISION {
+		return nil, d.malformed(encoding.ErrFloatValueEncodingPrecisionTooBig)
 	}
-	pos += posChange
 
 	// now that we have decoding options, we can decode attestation data.
 	// this function removes padding if the encoded value is a string
-	attestationData, err := encoding.DecodeAttestationData(attestationDataBytes, attestationDataLen, encodingOptions)
+	d.field = "attestation data"
+	d.fieldPos = attestationDataPos
+	d.fieldLen = len(attestationDataBytes)
+	if priceFeed != nil && encodingOptions.Value == encoding.ENCODING_OPTION_STRING {
+		return nil, d.malformed(ErrUnsupportedPriceFeedEncoding)
+	}
+	attestationData, err := encoding.DecodeAttestationData(attestationDataBytes, attestationDataStringLen, encodingOptions)
 	if err != nil {
-		return nil, err
+		return nil, d.malformed(err)
 	}
 
 	if encodingOptions.Value == encoding.ENCODING_OPTION_FLOAT {
@@ -153,33 +369,33 @@ func DecodeProofData(buf []byte) (*DecodedProofData, error) {
 	} else if encodingOptions.Value == encoding.ENCODING_OPTION_STRING {
 		attestationData = strings.TrimRight(attestationData, "\000")
 	}
+	attestationValue := decodeAttestationValue(attestationDataBytes, encodingOptions, attestationData)
 
 	// decode request headers
-	headersBytes, posChange, err := getBlockSlice(buf, pos, header.HeadersLen)
+	headersBytes, err := d.next("request headers", header.HeadersLen)
 	if err != nil {
 		return nil, err
 	}
 	requestHeaders, err := encoding.DecodeHeaders(headersBytes)
 	if err != nil {
-		return nil, err
+		return nil, d.malformed(err)
 	}
-	pos += posChange
 
 	// decode optional fields
-	optionalFieldsBytes, posChange, err := getBlockSlice(buf, pos, header.OptionalFieldsLen)
+	optionalFieldsBytes, err := d.next("optional fields", header.OptionalFieldsLen)
 	if err != nil {
 		return nil, err
 	}
-	htmlResultType, contentType, body, err := encoding.DecodeOptionalFields(optionalFieldsBytes)
+	htmlResultType, contentType, body, err := decodeOptionalFields(optionalFieldsBytes)
 	if err != nil {
-		return nil, err
+		return nil, d.malformed(err)
 	}
-	pos += posChange
 
 	return &DecodedProofData{
 		Timestamp:          int64(timestamp),
 		ResponseStatusCode: int(statusCode),
 		AttestationData:    attestationData,
+		AttestationValue:   attestationValue,
 
 		AttestationRequest: AttestationRequest{
 			Url:            url,
@@ -196,5 +412,7 @@ func DecodeProofData(buf []byte) (*DecodedProofData, error) {
 
 			DebugRequest: false,
 		},
+
+		PriceFeed: priceFeed,
 	}, nil
 }
@@ -1,8 +1,11 @@
 package attestation
 
 import (
+	"encoding/binary"
+	"errors"
 	"net/http"
 	"reflect"
+	"slices"
 	"testing"
 
 	encoding "github.com/zkportal/aleo-oracle-encoding"
@@ -30,6 +33,7 @@ func Test_decodeProofData(t *testing.T) {
 			want: &DecodedProofData{
 				ResponseStatusCode: 200,
 				AttestationData:    "string",
+				AttestationValue:   &AttestationValue{Type: "string", Text: "string"},
 				Timestamp:          1701851063,
 				AttestationRequest: AttestationRequest{
 					Url:                "https://localhost:8080/resource",
@@ -64,3 +68,309 @@ func Test_decodeProofData(t *testing.T) {
 		})
 	}
 }
+
+// encodes a valid attestation response for decoding tests
+func encodedProofData(t testing.TB) []byte {
+	htmlResultType := "element"
+	requestBody := "{\"userid\": 123456}"
+	contentType := "application/json"
+
+	buf, err := PrepareProofData(http.StatusOK, "42.5", 1701851063, &AttestationRequest{
+		Url:                "localhost:8080/resource",
+		RequestMethod:      http.MethodPost,
+		Selector:           "/html/body/div",
+		ResponseFormat:     "html",
+		HTMLResultType:     &htmlResultType,
+		RequestBody:        &requestBody,
+		RequestContentType: &contentType,
+		RequestHeaders: map[string]string{
+			"User-Agent": "curl 1.2.3",
+		},
+		EncodingOptions: encoding.EncodingOptions{
+			Value:     encoding.ENCODING_OPTION_FLOAT,
+			Precision: 2,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf
+}
+
+func Test_decodeProofDataErrors(t *testing.T) {
+	valid := encodedProofData(t)
+
+	// returns a copy of the valid proof data with a 2-byte meta header length replaced
+	withHeaderLen := func(offset int, value uint16) []byte {
+		buf := slices.Clone(valid)
+		binary.LittleEndian.PutUint16(buf[offset:offset+2], value)
+		return buf
+	}
+
+	// returns a copy of the valid proof data with an 8-byte optional field length replaced. The request body is the last
+	// optional field of 2 blocks, its length is in the block before the body. The content type is 1 block before that
+	withOptionalLen := func(blocksFromEnd int, value uint64) []byte {
+		buf := slices.Clone(valid)
+		binary.LittleEndian.PutUint64(buf[len(buf)-blocksFromEnd*encoding.TARGET_ALIGNMENT:], value)
+		return buf
+	}
+	const bodyLenBlock, contentTypeLenBlock = 3, 5
+
+	// the precision of a float is the 9th byte of the encoding options block, which is followed by 3 blocks of request headers and 7 blocks of optional fields
+	floatPrecisionTooBig := slices.Clone(valid)
+	floatPrecisionTooBig[len(valid)-11*encoding.TARGET_ALIGNMENT+8] = 255
+
+	// the number of blocks after the optional fields header wraps around to the right length when multiplied by the block size
+	blockCountOverflow := slices.Clone(valid)
+	binary.LittleEndian.PutUint64(blockCountOverflow[len(valid)-7*encoding.TARGET_ALIGNMENT+encoding.TARGET_ALIGNMENT/2:], 1<<60+6)
+
+	tests := []struct {
+		name      string
+		buf       []byte
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:      "empty",
+			buf:       nil,
+			wantErr:   ErrProofDataTooShort,
+			wantField: "meta header",
+		},
+		{
+			name:      "truncated",
+			buf:       valid[:len(valid)-encoding.TARGET_ALIGNMENT],
+			wantErr:   ErrFieldOutOfBounds,
+			wantField: "optional fields",
+		},
+		{
+			name:      "zero timestamp length",
+			buf:       withHeaderLen(2, 0),
+			wantErr:   ErrUnexpectedFieldLength,
+			wantField: "timestamp",
+		},
+		{
+			name:      "wrong status code length",
+			buf:       withHeaderLen(4, 16),
+			wantErr:   ErrUnexpectedFieldLength,
+			wantField: "status code",
+		},
+		{
+			name:      "url past the end",
+			buf:       withHeaderLen(10, 0xffff),
+			wantErr:   ErrFieldOutOfBounds,
+			wantField: "url",
+		},
+		{
+			name:      "wrong response format length",
+			buf:       withHeaderLen(8, 0),
+			wantErr:   ErrUnexpectedFieldLength,
+			wantField: "response format",
+		},
+		{
+			name:      "wrong encoding options length",
+			buf:       withHeaderLen(14, 8),
+			wantErr:   ErrUnexpectedFieldLength,
+			wantField: "encoding options",
+		},
+		{
+			name:      "headers length mismatch",
+			buf:       withHeaderLen(16, 0),
+			wantErr:   ErrMalformedField,
+			wantField: "request headers",
+		},
+		{
+			name:      "float precision too big",
+			buf:       floatPrecisionTooBig,
+			wantErr:   encoding.ErrFloatValueEncodingPrecisionTooBig,
+			wantField: "encoding options",
+		},
+		{
+			name:      "request body past the end",
+			buf:       withOptionalLen(bodyLenBlock, 3*encoding.TARGET_ALIGNMENT),
+			wantErr:   encoding.ErrDecodingOptionalsInvalidBodyLength,
+			wantField: "optional fields",
+		},
+		{
+			name:      "request body length overflows int",
+			buf:       withOptionalLen(bodyLenBlock, 1<<63),
+			wantErr:   encoding.ErrDecodingOptionalsInvalidBodyLength,
+			wantField: "optional fields",
+		},
+		{
+			name:      "content type past the end",
+			buf:       withOptionalLen(contentTypeLenBlock, 5*encoding.TARGET_ALIGNMENT),
+			wantErr:   encoding.ErrDecodingOptionalsInvalidContentTypeLength,
+			wantField: "optional fields",
+		},
+		{
+			name:      "content type into the request body",
+			buf:       withOptionalLen(contentTypeLenBlock, 3*encoding.TARGET_ALIGNMENT),
+			wantErr:   encoding.ErrDecodingOptionalsInvalidBodyLength,
+			wantField: "optional fields",
+		},
+		{
+			name:      "content type length overflows int",
+			buf:       withOptionalLen(contentTypeLenBlock, 1<<64-1),
+			wantErr:   encoding.ErrDecodingOptionalsInvalidContentTypeLength,
+			wantField: "optional fields",
+		},
+		{
+			name:      "wrong optional fields block count",
+			buf:       blockCountOverflow,
+			wantErr:   encoding.ErrDecodingOptionalsCountLengthMismatch,
+			wantField: "optional fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeProofData(tt.buf)
+			if got != nil {
+				t.Errorf("DecodeProofData() = %v, want nil", got)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeProofData() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("DecodeProofData() error = %T, want *DecodeError", err)
+			}
+
+			if decodeErr.Field != tt.wantField {
+				t.Errorf("DecodeError.Field = %q, want %q", decodeErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+// the seed corpus in testdata/fuzz/FuzzDecodeProofData has malformed field lengths and encoding options
+// that used to make the decoding panic
+func FuzzDecodeProofData(f *testing.F) {
+	valid := encodedProofData(f)
+
+	f.Add(valid)
+	f.Add([]byte{})
+	f.Add(valid[:encoding.TARGET_ALIGNMENT*2])
+	f.Add(valid[:len(valid)-encoding.TARGET_ALIGNMENT])
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		decoded, err := DecodeProofData(buf)
+		if err == nil {
+			if decoded == nil {
+				t.Fatal("DecodeProofData() returned neither data nor error")
+			}
+			return
+		}
+
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("DecodeProofData() error = %T, want *DecodeError", err)
+		}
+	})
+}
+
+func Test_decodePriceFeedProofData(t *testing.T) {
+	priceFeedRequest := func(url string, options encoding.EncodingOptions) *AttestationRequest {
+		return &AttestationRequest{
+			Url:             url,
+			RequestMethod:   http.MethodGet,
+			ResponseFormat:  "json",
+			Selector:        "weightedAvgPrice",
+			EncodingOptions: options,
+		}
+	}
+
+	floatOptions := encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_FLOAT, Precision: 6}
+	intOptions := encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_INT}
+
+	tests := []struct {
+		name          string
+		data          string
+		request       *AttestationRequest
+		tag           uint8
+		wantData      string
+		wantPriceFeed string
+		wantErr       error
+	}{
+		{
+			name:          "btc float",
+			data:          "63421.531234",
+			request:       priceFeedRequest(PriceFeedBtcUrl, floatOptions),
+			tag:           12,
+			wantData:      "63421.531234",
+			wantPriceFeed: PriceFeedBtcUrl,
+		},
+		{
+			name:          "float with fewer digits than the precision",
+			data:          "0.31",
+			request:       priceFeedRequest(PriceFeedAleoUrl, floatOptions),
+			tag:           8,
+			wantData:      "0.310000",
+			wantPriceFeed: PriceFeedAleoUrl,
+		},
+		{
+			name:          "eth int",
+			data:          "3120",
+			request:       priceFeedRequest(PriceFeedEthUrl, intOptions),
+			tag:           11,
+			wantData:      "3120",
+			wantPriceFeed: PriceFeedEthUrl,
+		},
+		{
+			name:     "price feed url without the tag",
+			data:     "3120",
+			request:  priceFeedRequest(PriceFeedEthUrl, intOptions),
+			wantData: "3120",
+		},
+		{
+			name:    "string price feed",
+			data:    "3120",
+			request: priceFeedRequest(PriceFeedEthUrl, encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_STRING}),
+			tag:     11,
+			wantErr: ErrUnsupportedPriceFeedEncoding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := PrepareProofData(http.StatusOK, tt.data, 1701851063, tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.tag != 0 {
+				buf[0] = tt.tag
+			}
+
+			got, err := DecodeProofData(buf)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeProofData() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.AttestationData != tt.wantData {
+				t.Errorf("DecodeProofData() attestation data = %s, want %s", got.AttestationData, tt.wantData)
+			}
+
+			if got.Url != tt.request.Url || got.Timestamp != 1701851063 {
+				t.Errorf("DecodeProofData() = %v, want request %v", got, tt.request)
+			}
+
+			if tt.wantPriceFeed == "" {
+				if got.PriceFeed != nil {
+					t.Errorf("DecodeProofData() price feed = %v, want nil", got.PriceFeed)
+				}
+				return
+			}
+
+			if got.PriceFeed == nil || got.PriceFeed.Url != tt.wantPriceFeed {
+				t.Errorf("DecodeProofData() price feed = %v, want %s", got.PriceFeed, tt.wantPriceFeed)
+			}
+		})
+	}
+}
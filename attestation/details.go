@@ -0,0 +1,104 @@
+package attestation
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/blocky/nitrite"
+	ego_attestation "github.com/edgelesssys/ego/attestation"
+)
+
+var ErrUnexpectedParsedReport = errors.New("unexpected type of parsed report")
+
+// Important fields of a verified SGX report
+type SgxReportDetails struct {
+	UniqueID        string `json:"uniqueId"`
+	SignerID        string `json:"signerId"`
+	ProductID       string `json:"productId"`
+	SecurityVersion uint   `json:"securityVersion"`
+	Debug           bool   `json:"debug"`
+	TCBStatus       string `json:"tcbStatus"`
+}
+
+// Validity of the Nitro enclave certificate that signed the attestation document
+type NitroCertificateDetails struct {
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	// true if the document was created while the certificate was valid
+	ValidAtAttestationTime bool `json:"validAtAttestationTime"`
+}
+
+// Important fields of a verified Nitro attestation document
+type NitroReportDetails struct {
+	ModuleID string `json:"moduleId"`
+	// document creation time in milliseconds since epoch
+	Timestamp   uint64                  `json:"timestamp"`
+	PCRs        map[uint]string         `json:"pcrs"`
+	Nonce       string                  `json:"nonce"`
+	Certificate NitroCertificateDetails `json:"certificate"`
+}
+
+// Attested details of a report, only one of the fields is set depending on the report type.
+// All binary values are hex-encoded.
+type ReportDetails struct {
+	Sgx   *SgxReportDetails   `json:"sgx,omitempty"`
+	Nitro *NitroReportDetails `json:"nitro,omitempty"`
+}
+
+func getSgxReportDetails(report *ego_attestation.Report) *SgxReportDetails {
+	return &SgxReportDetails{
+		UniqueID:        hex.EncodeToString(report.UniqueID),
+		SignerID:        hex.EncodeToString(report.SignerID),
+		ProductID:       hex.EncodeToString(report.ProductID),
+		SecurityVersion: report.SecurityVersion,
+		Debug:           report.Debug,
+		TCBStatus:       report.TCBStatus.String(),
+	}
+}
+
+func getNitroReportDetails(doc *nitrite.Document) (*NitroReportDetails, error) {
+	cert, err := x509.ParseCertificate(doc.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	pcrs := make(map[uint]string, len(doc.PCRs))
+	for idx, pcr := range doc.PCRs {
+		pcrs[idx] = hex.EncodeToString(pcr)
+	}
+
+	createdAt := doc.CreatedAt()
+
+	return &NitroReportDetails{
+		ModuleID:  doc.ModuleID,
+		Timestamp: doc.Timestamp,
+		PCRs:      pcrs,
+		Nonce:     hex.EncodeToString(doc.Nonce),
+		Certificate: NitroCertificateDetails{
+			NotBefore:              cert.NotBefore.UTC(),
+			NotAfter:               cert.NotAfter.UTC(),
+			ValidAtAttestationTime: !createdAt.Before(cert.NotBefore) && !createdAt.After(cert.NotAfter),
+		},
+	}, nil
+}
+
+// GetReportDetails extracts the attested details from a report returned by VerifyReport
+func GetReportDetails(parsedReport interface{}) (*ReportDetails, error) {
+	switch report := parsedReport.(type) {
+	case *ego_attestation.Report:
+		return &ReportDetails{Sgx: getSgxReportDetails(report)}, nil
+
+	case *nitrite.Document:
+		details, err := getNitroReportDetails(report)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ReportDetails{Nitro: details}, nil
+
+	default:
+		return nil, ErrUnexpectedParsedReport
+	}
+}
@@ -0,0 +1,138 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/blocky/nitrite"
+	ego_attestation "github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+func createTestCertificate(t *testing.T, notBefore, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test enclave"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestGetReportDetails(t *testing.T) {
+	notBefore := time.Date(2024, 9, 9, 8, 9, 0, 0, time.UTC)
+	notAfter := notBefore.Add(3 * time.Hour)
+	cert := createTestCertificate(t, notBefore, notAfter)
+
+	tests := []struct {
+		name    string
+		report  interface{}
+		want    *ReportDetails
+		wantErr bool
+	}{
+		{
+			name: "sgx",
+			report: &ego_attestation.Report{
+				UniqueID:        []byte{0x44, 0x6a},
+				SignerID:        []byte{0xf4, 0x7e},
+				ProductID:       []byte{1, 0},
+				SecurityVersion: 2,
+				Debug:           false,
+				TCBStatus:       tcbstatus.SWHardeningNeeded,
+			},
+			want: &ReportDetails{
+				Sgx: &SgxReportDetails{
+					UniqueID:        "446a",
+					SignerID:        "f47e",
+					ProductID:       "0100",
+					SecurityVersion: 2,
+					Debug:           false,
+					TCBStatus:       "SWHardeningNeeded",
+				},
+			},
+		},
+		{
+			name: "nitro",
+			report: &nitrite.Document{
+				ModuleID:    "i-02dd0abe215ecea89-enc0191d5d43e5aa019",
+				Timestamp:   uint64(notBefore.Add(time.Hour).UnixMilli()),
+				PCRs:        map[uint][]byte{0: {0x89, 0xf6}, 1: {0x03, 0x43}},
+				Certificate: cert,
+				Nonce:       []byte{0xe1, 0x42},
+			},
+			want: &ReportDetails{
+				Nitro: &NitroReportDetails{
+					ModuleID:  "i-02dd0abe215ecea89-enc0191d5d43e5aa019",
+					Timestamp: uint64(notBefore.Add(time.Hour).UnixMilli()),
+					PCRs:      map[uint]string{0: "89f6", 1: "0343"},
+					Nonce:     "e142",
+					Certificate: NitroCertificateDetails{
+						NotBefore:              notBefore,
+						NotAfter:               notAfter,
+						ValidAtAttestationTime: true,
+					},
+				},
+			},
+		},
+		{
+			name: "nitro certificate expired at attestation time",
+			report: &nitrite.Document{
+				Timestamp:   uint64(notAfter.Add(time.Minute).UnixMilli()),
+				Certificate: cert,
+			},
+			want: &ReportDetails{
+				Nitro: &NitroReportDetails{
+					Timestamp: uint64(notAfter.Add(time.Minute).UnixMilli()),
+					PCRs:      map[uint]string{},
+					Nonce:     "",
+					Certificate: NitroCertificateDetails{
+						NotBefore:              notBefore,
+						NotAfter:               notAfter,
+						ValidAtAttestationTime: false,
+					},
+				},
+			},
+		},
+		{
+			name:    "nitro invalid certificate",
+			report:  &nitrite.Document{Certificate: []byte{1, 2, 3}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown report",
+			report:  "report",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetReportDetails(tt.report)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetReportDetails() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetReportDetails() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
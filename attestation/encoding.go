@@ -11,6 +11,7 @@ import (
 	"github.com/zkportal/aleo-oracle-encoding/positionRecorder"
 )
 
+// pseudo-URLs of the default price feeds
 const (
 	PriceFeedBtcUrl  = "price_feed: btc"
 	PriceFeedEthUrl  = "price_feed: eth"
@@ -44,7 +45,8 @@ func prepareAttestationData(attestationData string, encodingOptions *encoding.En
 func PrepareProofData(statusCode int, attestationData string, timestamp int64, req *AttestationRequest) ([]byte, error) {
 	preppedAttestationData := attestationData
 
-	if req.Url != PriceFeedBtcUrl && req.Url != PriceFeedEthUrl && req.Url != PriceFeedAleoUrl {
+	// price feeds are not padded unless they're configured otherwise
+	if feed := GetPriceFeed(req.Url); feed == nil || feed.PadAttestationData {
 		preppedAttestationData = prepareAttestationData(attestationData, &req.EncodingOptions)
 	}
 
@@ -0,0 +1,70 @@
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/blocky/nitrite"
+)
+
+// timestamps can be slightly ahead of the reference time because of clock differences between the oracle and this backend
+const allowedClockSkew = time.Minute
+
+var (
+	ErrAttestationTooOld     = errors.New("attestation timestamp is older than allowed")
+	ErrAttestationFromFuture = errors.New("attestation timestamp is after the reference time")
+	ErrDocumentTooOld        = errors.New("nitro document timestamp is older than allowed")
+	ErrDocumentFromFuture    = errors.New("nitro document timestamp is after the reference time")
+)
+
+// FreshnessPolicy limits how old a report can be at the reference time. Zero values disable the checks.
+type FreshnessPolicy struct {
+	// maximum age of the attestation timestamp, in seconds
+	MaxAttestationAge uint64 `json:"maxAttestationAgeSeconds"`
+	// maximum age of the Nitro attestation document timestamp, in seconds
+	MaxDocumentAge uint64 `json:"maxDocumentAgeSeconds"`
+	// accept an "as of" time in verification requests. The time comes from the client, so a report
+	// verified as of it must be acceptable at the current time too
+	AllowAsOf bool `json:"allowAsOf"`
+}
+
+func checkTimestamp(timestamp, referenceTime time.Time, maxAgeSeconds uint64, errTooOld, errFromFuture error) error {
+	if maxAgeSeconds == 0 {
+		return nil
+	}
+
+	if timestamp.After(referenceTime.Add(allowedClockSkew)) {
+		return fmt.Errorf("%w: timestamp=%s, reference=%s", errFromFuture, timestamp.UTC().Format(time.RFC3339), referenceTime.UTC().Format(time.RFC3339))
+	}
+
+	maxAge := time.Duration(maxAgeSeconds) * time.Second
+	if referenceTime.Sub(timestamp) > maxAge {
+		return fmt.Errorf("%w: timestamp=%s, reference=%s, max age=%s", errTooOld, timestamp.UTC().Format(time.RFC3339), referenceTime.UTC().Format(time.RFC3339), maxAge)
+	}
+
+	return nil
+}
+
+// CheckFreshness checks the attestation timestamp and, for Nitro reports, the attestation document timestamp
+// against the reference time. Use the current time for live verification, or a past time to verify archived reports
+// as of that time.
+func CheckFreshness(resp *AttestationResponse, verifiedReport *VerifiedReport, policy *FreshnessPolicy, referenceTime time.Time) error {
+	if policy == nil {
+		return nil
+	}
+
+	err := checkTimestamp(time.Unix(resp.Timestamp, 0), referenceTime, policy.MaxAttestationAge, ErrAttestationTooOld, ErrAttestationFromFuture)
+	if err != nil {
+		return err
+	}
+
+	if doc, ok := verifiedReport.Report.(*nitrite.Document); ok {
+		err = checkTimestamp(doc.CreatedAt(), referenceTime, policy.MaxDocumentAge, ErrDocumentTooOld, ErrDocumentFromFuture)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
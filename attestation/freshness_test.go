@@ -0,0 +1,97 @@
+package attestation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blocky/nitrite"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	reference := time.Date(2024, 9, 9, 8, 0, 0, 0, time.UTC)
+
+	nitroReport := func(created time.Time) *VerifiedReport {
+		return &VerifiedReport{
+			Report: &nitrite.Document{Timestamp: uint64(created.UnixMilli())},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		report    *VerifiedReport
+		policy    *FreshnessPolicy
+		wantErr   error
+	}{
+		{
+			name:      "no policy",
+			timestamp: reference.Add(-24 * time.Hour),
+			report:    &VerifiedReport{},
+			policy:    nil,
+		},
+		{
+			name:      "disabled checks",
+			timestamp: reference.Add(-24 * time.Hour),
+			report:    nitroReport(reference.Add(-24 * time.Hour)),
+			policy:    &FreshnessPolicy{},
+		},
+		{
+			name:      "fresh",
+			timestamp: reference.Add(-10 * time.Second),
+			report:    &VerifiedReport{},
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60},
+		},
+		{
+			name:      "stale attestation",
+			timestamp: reference.Add(-61 * time.Second),
+			report:    &VerifiedReport{},
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60},
+			wantErr:   ErrAttestationTooOld,
+		},
+		{
+			name:      "attestation within clock skew",
+			timestamp: reference.Add(30 * time.Second),
+			report:    &VerifiedReport{},
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60},
+		},
+		{
+			name:      "attestation from the future",
+			timestamp: reference.Add(time.Hour),
+			report:    &VerifiedReport{},
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60},
+			wantErr:   ErrAttestationFromFuture,
+		},
+		{
+			name:      "fresh document",
+			timestamp: reference.Add(-10 * time.Second),
+			report:    nitroReport(reference.Add(-10 * time.Second)),
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60, MaxDocumentAge: 60},
+		},
+		{
+			name:      "stale document",
+			timestamp: reference.Add(-10 * time.Second),
+			report:    nitroReport(reference.Add(-2 * time.Minute)),
+			policy:    &FreshnessPolicy{MaxAttestationAge: 60, MaxDocumentAge: 60},
+			wantErr:   ErrDocumentTooOld,
+		},
+		{
+			name:      "document from the future",
+			timestamp: reference,
+			report:    nitroReport(reference.Add(time.Hour)),
+			policy:    &FreshnessPolicy{MaxDocumentAge: 60},
+			wantErr:   ErrDocumentFromFuture,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &AttestationResponse{Timestamp: tt.timestamp.Unix()}
+
+			err := CheckFreshness(resp, tt.report, tt.policy, reference)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckFreshness() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
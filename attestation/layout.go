@@ -0,0 +1,90 @@
+package attestation
+
+import (
+	"encoding/hex"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+// FieldLayout describes where a field is in the encoded proof data
+type FieldLayout struct {
+	Field string `json:"field"`
+	// byte offset of the field
+	Offset int `json:"offset"`
+	// index of the first block of the field
+	Block int `json:"block"`
+	// length of the field including padding
+	AlignedLength int `json:"alignedLength"`
+	// length of the field as declared in the meta header
+	DeclaredLength int `json:"declaredLength"`
+	// raw bytes of the field including padding
+	Hex string `json:"hex"`
+	// true if the field extends past the end of the proof data. Hex has the bytes that are available
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ProofDataLayout returns the positions of the fields in the proof data according to its meta header.
+// It doesn't decode the content of the fields, so it also works for proof data that cannot be decoded.
+// The layout stops at the first field that extends past the end of the data
+func ProofDataLayout(buf []byte) []FieldLayout {
+	var layout []FieldLayout
+	pos := 0
+
+	// adds a field to the layout, returns false if the field is truncated
+	add := func(field string, declaredLength int) bool {
+		alignedLength := alignToBlock(declaredLength)
+		end := min(pos+alignedLength, len(buf))
+
+		layout = append(layout, FieldLayout{
+			Field:          field,
+			Offset:         pos,
+			Block:          pos / encoding.TARGET_ALIGNMENT,
+			AlignedLength:  alignedLength,
+			DeclaredLength: declaredLength,
+			Hex:            hex.EncodeToString(buf[pos:end]),
+			Truncated:      pos+alignedLength > len(buf),
+		})
+		pos += alignedLength
+
+		return pos <= len(buf)
+	}
+
+	if !add("meta header", encoding.TARGET_ALIGNMENT*2) {
+		return layout
+	}
+
+	header, err := encoding.DecodeMetaHeader(buf[:encoding.TARGET_ALIGNMENT*2])
+	if err != nil {
+		return layout
+	}
+
+	// see DecodeProofData for the attestation data length
+	attestationDataLen := header.AttestationDataLen
+	if attestationDataLen == 255 || detectPriceFeed(buf, header) != nil {
+		attestationDataLen = encoding.TARGET_ALIGNMENT
+	}
+
+	fields := []struct {
+		name   string
+		length int
+	}{
+		{"attestation data", attestationDataLen},
+		{"timestamp", header.TimestampLen},
+		{"status code", header.StatusCodeLen},
+		{"url", header.UrlLen},
+		{"selector", header.SelectorLen},
+		{"response format", header.ResponseFormatLen},
+		{"request method", header.MethodLen},
+		{"encoding options", header.EncodingOptionsLen},
+		{"request headers", header.HeadersLen},
+		{"optional fields", header.OptionalFieldsLen},
+	}
+
+	for _, field := range fields {
+		if !add(field.name, field.length) {
+			break
+		}
+	}
+
+	return layout
+}
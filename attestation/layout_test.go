@@ -0,0 +1,80 @@
+package attestation
+
+import (
+	"encoding/hex"
+	"testing"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+func TestProofDataLayout(t *testing.T) {
+	valid := encodedProofData(t)
+
+	expectedFields := []string{
+		"meta header",
+		"attestation data",
+		"timestamp",
+		"status code",
+		"url",
+		"selector",
+		"response format",
+		"request method",
+		"encoding options",
+		"request headers",
+		"optional fields",
+	}
+
+	layout := ProofDataLayout(valid)
+	if len(layout) != len(expectedFields) {
+		t.Fatalf("expected %d fields, got %d", len(expectedFields), len(layout))
+	}
+
+	pos := 0
+	for idx, field := range layout {
+		if field.Field != expectedFields[idx] {
+			t.Errorf("field %d: expected %s, got %s", idx, expectedFields[idx], field.Field)
+		}
+
+		if field.Offset != pos || field.Block != pos/encoding.TARGET_ALIGNMENT {
+			t.Errorf("%s: expected offset %d, got %d (block %d)", field.Field, pos, field.Offset, field.Block)
+		}
+
+		if field.AlignedLength%encoding.TARGET_ALIGNMENT != 0 || field.AlignedLength < field.DeclaredLength {
+			t.Errorf("%s: invalid aligned length %d for declared length %d", field.Field, field.AlignedLength, field.DeclaredLength)
+		}
+
+		if field.Hex != hex.EncodeToString(valid[pos:pos+field.AlignedLength]) {
+			t.Errorf("%s: unexpected hex %s", field.Field, field.Hex)
+		}
+
+		if field.Truncated {
+			t.Errorf("%s: unexpected truncated field", field.Field)
+		}
+
+		pos += field.AlignedLength
+	}
+
+	if pos != len(valid) {
+		t.Errorf("expected the layout to cover %d bytes, got %d", len(valid), pos)
+	}
+
+	if layout[4].Hex[:2*len("localhost")] != hex.EncodeToString([]byte("localhost")) {
+		t.Errorf("unexpected url hex %s", layout[4].Hex)
+	}
+
+	// truncated in the middle of the url
+	truncated := ProofDataLayout(valid[:layout[4].Offset+4])
+	if len(truncated) != 5 {
+		t.Fatalf("expected the layout to stop at the url, got %d fields", len(truncated))
+	}
+
+	url := truncated[4]
+	if !url.Truncated || url.Hex != hex.EncodeToString(valid[url.Offset:url.Offset+4]) {
+		t.Errorf("unexpected truncated url layout %+v", url)
+	}
+
+	short := ProofDataLayout(valid[:10])
+	if len(short) != 1 || !short[0].Truncated {
+		t.Errorf("expected a truncated meta header, got %+v", short)
+	}
+}
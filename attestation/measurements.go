@@ -0,0 +1,130 @@
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sources of enclave measurements
+const (
+	MEASUREMENTS_SOURCE_CONFIG       = "config"
+	MEASUREMENTS_SOURCE_REPRODUCIBLE = "reproducible"
+	MEASUREMENTS_SOURCE_CONTRACT     = "contract"
+)
+
+// MeasurementSet is a set of accepted enclave measurements. A set can have SGX measurements, Nitro measurements, or both.
+// Reports are accepted if they match any set that is valid at verification time, which allows overlapping
+// windows during rolling upgrades of the oracle.
+type MeasurementSet struct {
+	Label string `json:"label"`
+	// where the measurements come from, e.g. "config", "reproducible", "contract"
+	Source string `json:"source,omitempty"`
+
+	// hex-encoded SGX unique ID
+	UniqueID string `json:"uniqueId,omitempty"`
+	// hex-encoded Nitro PCR 0, 1, 2 values
+	PcrValues []string `json:"pcrValues,omitempty"`
+
+	// optional validity window
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+}
+
+// IsValidAt checks if the set's validity window includes t
+func (m *MeasurementSet) IsValidAt(t time.Time) bool {
+	if m.NotBefore != nil && t.Before(*m.NotBefore) {
+		return false
+	}
+
+	if m.NotAfter != nil && t.After(*m.NotAfter) {
+		return false
+	}
+
+	return true
+}
+
+func (m *MeasurementSet) HasSgx() bool {
+	return m.UniqueID != ""
+}
+
+func (m *MeasurementSet) HasNitro() bool {
+	return len(m.PcrValues) == 3
+}
+
+// Validate checks that the set is usable. It doesn't check the measurement encoding.
+func (m *MeasurementSet) Validate() error {
+	if m.Label == "" {
+		return errors.New("measurement set must have a label")
+	}
+
+	if len(m.PcrValues) != 0 && len(m.PcrValues) != 3 {
+		return fmt.Errorf("measurement set \"%s\" must have 3 PCR values", m.Label)
+	}
+
+	if !m.HasSgx() && !m.HasNitro() {
+		return fmt.Errorf("measurement set \"%s\" must have a unique ID or PCR values", m.Label)
+	}
+
+	if m.NotBefore != nil && m.NotAfter != nil && m.NotAfter.Before(*m.NotBefore) {
+		return fmt.Errorf("measurement set \"%s\" has \"notAfter\" before \"notBefore\"", m.Label)
+	}
+
+	return nil
+}
+
+// returns the sets that are valid at time t
+func validMeasurementsAt(measurements []MeasurementSet, t time.Time) []*MeasurementSet {
+	result := make([]*MeasurementSet, 0, len(measurements))
+	for idx := range measurements {
+		if measurements[idx].IsValidAt(t) {
+			result = append(result, &measurements[idx])
+		}
+	}
+
+	return result
+}
+
+// MeasurementsValidAt returns the sets that are valid at time t
+func MeasurementsValidAt(measurements []MeasurementSet, t time.Time) []MeasurementSet {
+	result := make([]MeasurementSet, 0, len(measurements))
+	for _, set := range measurements {
+		if set.IsValidAt(t) {
+			result = append(result, set)
+		}
+	}
+
+	return result
+}
+
+// MeasurementRegistry holds the accepted measurement sets, which can be replaced while the backend is running,
+// e.g. when the live contract switches to new measurements. A nil registry has no sets.
+type MeasurementRegistry struct {
+	mu   sync.RWMutex
+	sets []MeasurementSet
+}
+
+func NewMeasurementRegistry(sets []MeasurementSet) *MeasurementRegistry {
+	return &MeasurementRegistry{sets: sets}
+}
+
+// Sets returns the current measurement sets. The slice must not be modified, use Replace instead
+func (r *MeasurementRegistry) Sets() []MeasurementSet {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sets
+}
+
+// Replace replaces the measurement sets. Verifications that already started keep using the old sets
+func (r *MeasurementRegistry) Replace(sets []MeasurementSet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sets = sets
+}
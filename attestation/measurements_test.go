@@ -0,0 +1,163 @@
+package attestation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeasurementSet_IsValidAt(t *testing.T) {
+	notBefore := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		set  MeasurementSet
+		at   time.Time
+		want bool
+	}{
+		{
+			name: "no window",
+			set:  MeasurementSet{},
+			at:   notBefore,
+			want: true,
+		},
+		{
+			name: "before window",
+			set:  MeasurementSet{NotBefore: &notBefore, NotAfter: &notAfter},
+			at:   notBefore.Add(-time.Second),
+			want: false,
+		},
+		{
+			name: "window start",
+			set:  MeasurementSet{NotBefore: &notBefore, NotAfter: &notAfter},
+			at:   notBefore,
+			want: true,
+		},
+		{
+			name: "window end",
+			set:  MeasurementSet{NotBefore: &notBefore, NotAfter: &notAfter},
+			at:   notAfter,
+			want: true,
+		},
+		{
+			name: "after window",
+			set:  MeasurementSet{NotBefore: &notBefore, NotAfter: &notAfter},
+			at:   notAfter.Add(time.Second),
+			want: false,
+		},
+		{
+			name: "open end",
+			set:  MeasurementSet{NotBefore: &notBefore},
+			at:   notAfter.Add(time.Hour),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.IsValidAt(tt.at); got != tt.want {
+				t.Errorf("MeasurementSet.IsValidAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeasurementSet_Validate(t *testing.T) {
+	notBefore := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		set     MeasurementSet
+		wantErr bool
+	}{
+		{
+			name: "sgx only",
+			set:  MeasurementSet{Label: "v1", UniqueID: "446a"},
+		},
+		{
+			name: "nitro only",
+			set:  MeasurementSet{Label: "v1", PcrValues: []string{"00", "01", "02"}},
+		},
+		{
+			name:    "no label",
+			set:     MeasurementSet{UniqueID: "446a"},
+			wantErr: true,
+		},
+		{
+			name:    "no measurements",
+			set:     MeasurementSet{Label: "v1"},
+			wantErr: true,
+		},
+		{
+			name:    "wrong number of PCR values",
+			set:     MeasurementSet{Label: "v1", PcrValues: []string{"00"}},
+			wantErr: true,
+		},
+		{
+			name:    "inverted window",
+			set:     MeasurementSet{Label: "v1", UniqueID: "446a", NotBefore: &notAfter, NotAfter: &notBefore},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.set.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("MeasurementSet.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validMeasurementsAt(t *testing.T) {
+	upgrade := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	retire := upgrade.Add(24 * time.Hour)
+
+	measurements := []MeasurementSet{
+		{Label: "old", UniqueID: "01", NotAfter: &retire},
+		{Label: "new", UniqueID: "02", NotBefore: &upgrade},
+	}
+
+	labels := func(sets []*MeasurementSet) []string {
+		result := make([]string, 0, len(sets))
+		for _, set := range sets {
+			result = append(result, set.Label)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		{name: "before upgrade", at: upgrade.Add(-time.Hour), want: []string{"old"}},
+		{name: "overlap", at: upgrade.Add(time.Hour), want: []string{"old", "new"}},
+		{name: "after retirement", at: retire.Add(time.Hour), want: []string{"new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := labels(validMeasurementsAt(measurements, tt.at))
+			if len(got) != len(tt.want) {
+				t.Fatalf("validMeasurementsAt() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("validMeasurementsAt() = %v, want %v", got, tt.want)
+				}
+			}
+
+			sets := MeasurementsValidAt(measurements, tt.at)
+			if len(sets) != len(tt.want) {
+				t.Fatalf("MeasurementsValidAt() = %+v, want %v", sets, tt.want)
+			}
+			for i := range sets {
+				if sets[i].Label != tt.want[i] {
+					t.Errorf("MeasurementsValidAt() = %+v, want %v", sets, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -13,6 +13,12 @@ import (
 	"github.com/zkportal/oracle-verification-backend/u128"
 )
 
+var (
+	ErrNonceMismatch            = errors.New("error verifying nitro report: nonce missmatched")
+	ErrPcrValuesMismatch        = errors.New("report PCR values don't match target")
+	ErrUnexpectedUserDataLength = errors.New("unexpected length of the attestation report data")
+)
+
 var verifier *nitrite.Verifier
 var initErr error
 var initOnce sync.Once
@@ -40,20 +46,22 @@ func Init() error {
 	return initErr
 }
 
-func VerifyNitroReport(reportBytes []byte, nonceString string, targetPcrValues [3]string) (*nitrite.Document, error) {
+// VerifyNitroReport verifies the attestation document and matches its PCR 0-2 values to
+// one of the targets. Returns the index of the matched target.
+func VerifyNitroReport(reportBytes []byte, nonceString string, targetPcrValues [][3]string) (*nitrite.Document, int, error) {
 	if verifier == nil {
 		panic("nitro verifier is not initialized")
 	}
 
 	report, err := verifier.Verify(reportBytes)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
 	nonce := hex.EncodeToString(report.Nonce)
 
 	if nonceString != "" && nonceString != nonce {
-		return nil, errors.New("error verifying nitro report: nonce missmatched")
+		return nil, -1, ErrNonceMismatch
 	}
 
 	var pcrValues [3]string
@@ -62,18 +70,24 @@ func VerifyNitroReport(reportBytes []byte, nonceString string, targetPcrValues [
 		pcrValues[i] = hex.EncodeToString(report.PCRs[i])
 	}
 
-	if !slices.Equal(pcrValues[:], targetPcrValues[:]) {
-		log.Printf("reporting enclave PCR values don't match the expected ones, expected=[%s], got=[%s]", strings.Join(targetPcrValues[:], ", "), strings.Join(pcrValues[:], ", "))
-		return nil, errors.New("report PCR values don't match target")
+	targetIdx := slices.Index(targetPcrValues, pcrValues)
+	if targetIdx == -1 {
+		targets := make([]string, 0, len(targetPcrValues))
+		for _, target := range targetPcrValues {
+			targets = append(targets, "["+strings.Join(target[:], ", ")+"]")
+		}
+
+		log.Printf("reporting enclave PCR values don't match the expected ones, expected=[%s], got=[%s]", strings.Join(targets, ", "), strings.Join(pcrValues[:], ", "))
+		return nil, -1, ErrPcrValuesMismatch
 	}
 
 	if len(report.UserData) != 16 {
-		return nil, errors.New("unexpected length of the attestation report data")
+		return nil, -1, ErrUnexpectedUserDataLength
 	}
 
 	nitriteDocument := nitrite.Document(report)
 
-	return &nitriteDocument, nil
+	return &nitriteDocument, targetIdx, nil
 }
 
 func FormatPcrValues(pcrs [3][48]byte) string {
@@ -0,0 +1,94 @@
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrPriceFeedNoUrl        = errors.New("price feed must have a url")
+	ErrPriceFeedNoTag        = errors.New("price feed must have a non-zero tag")
+	ErrPriceFeedDuplicateUrl = errors.New("price feed url is registered more than once")
+	ErrPriceFeedDuplicateTag = errors.New("price feed tag is registered more than once")
+)
+
+// PriceFeed is a special attestation source of the oracle, which is identified by a pseudo-URL
+type PriceFeed struct {
+	// pseudo-URL in the attestation request, e.g. "price_feed: btc"
+	Url string `json:"url"`
+	// the enclave writes the tag to the first byte of the proof data meta header
+	Tag uint8 `json:"tag"`
+	// if true, the attestation data is padded according to the encoding options like for other requests.
+	// The oracle doesn't pad price feed data by default
+	PadAttestationData bool `json:"padAttestationData"`
+}
+
+// DefaultPriceFeeds returns the price feeds that the oracle provides
+func DefaultPriceFeeds() []PriceFeed {
+	return []PriceFeed{
+		{Url: PriceFeedAleoUrl, Tag: 8},
+		{Url: PriceFeedEthUrl, Tag: 11},
+		{Url: PriceFeedBtcUrl, Tag: 12},
+	}
+}
+
+// ValidatePriceFeeds checks that every price feed has a URL and a tag, and that they are unique
+func ValidatePriceFeeds(feeds []PriceFeed) error {
+	urls := make(map[string]bool, len(feeds))
+	tags := make(map[uint8]bool, len(feeds))
+
+	for idx, feed := range feeds {
+		if feed.Url == "" {
+			return fmt.Errorf("price feed %d: %w", idx, ErrPriceFeedNoUrl)
+		}
+
+		if feed.Tag == 0 {
+			return fmt.Errorf("price feed \"%s\": %w", feed.Url, ErrPriceFeedNoTag)
+		}
+
+		if urls[feed.Url] {
+			return fmt.Errorf("price feed \"%s\": %w", feed.Url, ErrPriceFeedDuplicateUrl)
+		}
+		urls[feed.Url] = true
+
+		if tags[feed.Tag] {
+			return fmt.Errorf("price feed \"%s\": %w", feed.Url, ErrPriceFeedDuplicateTag)
+		}
+		tags[feed.Tag] = true
+	}
+
+	return nil
+}
+
+// registered price feeds, replaced with SetPriceFeeds during startup
+var priceFeeds = DefaultPriceFeeds()
+
+// SetPriceFeeds replaces the registered price feeds. Must be called before verifying or encoding any reports
+func SetPriceFeeds(feeds []PriceFeed) error {
+	if err := ValidatePriceFeeds(feeds); err != nil {
+		return err
+	}
+
+	priceFeeds = slices.Clone(feeds)
+
+	return nil
+}
+
+// PriceFeeds returns the registered price feeds
+func PriceFeeds() []PriceFeed {
+	return slices.Clone(priceFeeds)
+}
+
+// GetPriceFeed returns the registered price feed with the URL, or nil if the URL is not a price feed
+func GetPriceFeed(url string) *PriceFeed {
+	idx := slices.IndexFunc(priceFeeds, func(feed PriceFeed) bool {
+		return feed.Url == url
+	})
+	if idx == -1 {
+		return nil
+	}
+
+	feed := priceFeeds[idx]
+	return &feed
+}
@@ -0,0 +1,86 @@
+package attestation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePriceFeeds(t *testing.T) {
+	tests := []struct {
+		name    string
+		feeds   []PriceFeed
+		wantErr error
+	}{
+		{
+			name:  "defaults",
+			feeds: DefaultPriceFeeds(),
+		},
+		{
+			name: "no feeds",
+		},
+		{
+			name:    "no url",
+			feeds:   []PriceFeed{{Tag: 1}},
+			wantErr: ErrPriceFeedNoUrl,
+		},
+		{
+			name:    "no tag",
+			feeds:   []PriceFeed{{Url: "price_feed: sol"}},
+			wantErr: ErrPriceFeedNoTag,
+		},
+		{
+			name:    "duplicate url",
+			feeds:   []PriceFeed{{Url: "price_feed: sol", Tag: 1}, {Url: "price_feed: sol", Tag: 2}},
+			wantErr: ErrPriceFeedDuplicateUrl,
+		},
+		{
+			name:    "duplicate tag",
+			feeds:   []PriceFeed{{Url: "price_feed: sol", Tag: 1}, {Url: "price_feed: dot", Tag: 1}},
+			wantErr: ErrPriceFeedDuplicateTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePriceFeeds(tt.feeds)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSetPriceFeeds(t *testing.T) {
+	defer SetPriceFeeds(DefaultPriceFeeds())
+
+	if feed := GetPriceFeed(PriceFeedBtcUrl); feed == nil || feed.Tag != 12 {
+		t.Fatalf("expected the default btc price feed, got %v", feed)
+	}
+
+	err := SetPriceFeeds([]PriceFeed{{Url: "price_feed: sol", Tag: 13, PadAttestationData: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if feed := GetPriceFeed(PriceFeedBtcUrl); feed != nil {
+		t.Errorf("expected btc not to be a price feed, got %v", feed)
+	}
+
+	feed := GetPriceFeed("price_feed: sol")
+	if feed == nil || feed.Tag != 13 || !feed.PadAttestationData {
+		t.Errorf("expected the sol price feed, got %v", feed)
+	}
+
+	if len(PriceFeeds()) != 1 {
+		t.Errorf("expected 1 price feed, got %d", len(PriceFeeds()))
+	}
+
+	err = SetPriceFeeds([]PriceFeed{{Url: "price_feed: sol"}})
+	if !errors.Is(err, ErrPriceFeedNoTag) {
+		t.Errorf("expected error %v, got %v", ErrPriceFeedNoTag, err)
+	}
+
+	if GetPriceFeed("price_feed: sol") == nil {
+		t.Error("expected an invalid registry not to replace the price feeds")
+	}
+}
@@ -0,0 +1,149 @@
+package sgx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+// Ways of matching a report to the expected enclave
+const (
+	// compare the enclave unique ID (MRENCLAVE) with the target unique ID
+	MATCH_BY_UNIQUE_ID = "uniqueId"
+	// compare the enclave signer ID (MRSIGNER) and product ID (ISVPRODID) with the ones in the policy
+	MATCH_BY_SIGNER = "signer"
+
+	expectedSignerIdLength = 32
+)
+
+// TCB statuses accepted in addition to "UpToDate" when a policy doesn't list any. The Open Enclave verification
+// accepts reports with these statuses
+var DefaultAllowedTcbStatuses = []string{tcbstatus.SWHardeningNeeded.String()}
+
+var (
+	ErrDebugEnclave          = errors.New("report is produced by a debug enclave")
+	ErrSecurityVersionTooLow = errors.New("report enclave security version is lower than required")
+	ErrTcbStatusRejected     = errors.New("report TCB status is not accepted")
+	ErrSignerMismatch        = errors.New("report signer ID or product ID doesn't match target")
+)
+
+// Policy describes which SGX reports are acceptable beyond a valid signature.
+// The zero value accepts non-debug enclaves with a TCB status in DefaultAllowedTcbStatuses or up to date, matched by unique ID.
+type Policy struct {
+	AllowDebug         bool `json:"allowDebug"`
+	MinSecurityVersion uint `json:"minSecurityVersion"`
+	// Names of accepted TCB statuses, e.g. "SWHardeningNeeded". "UpToDate" is always accepted.
+	// DefaultAllowedTcbStatuses if nil, an empty list accepts only "UpToDate"
+	AllowedTcbStatuses []string `json:"allowedTcbStatuses"`
+	// One of MATCH_BY_UNIQUE_ID (default) or MATCH_BY_SIGNER
+	MatchBy string `json:"matchBy"`
+	// Expected enclave signer ID, hex or base64 encoded. Required when matching by signer
+	SignerID string `json:"signerId"`
+	// Expected enclave product ID. Used when matching by signer
+	ProductID uint16 `json:"productId"`
+}
+
+func parseTcbStatus(name string) (tcbstatus.Status, bool) {
+	for status := tcbstatus.UpToDate; status <= tcbstatus.Unknown; status++ {
+		if status.String() == name {
+			return status, true
+		}
+	}
+
+	return tcbstatus.Unknown, false
+}
+
+// Validate checks the policy for correctness and converts the signer ID to hex.
+func (p *Policy) Validate() error {
+	for _, name := range p.AllowedTcbStatuses {
+		if _, ok := parseTcbStatus(name); !ok {
+			return fmt.Errorf("unknown SGX TCB status \"%s\"", name)
+		}
+	}
+
+	switch p.MatchBy {
+	case "":
+		p.MatchBy = MATCH_BY_UNIQUE_ID
+	case MATCH_BY_UNIQUE_ID:
+	case MATCH_BY_SIGNER:
+		if p.SignerID == "" {
+			return errors.New("SGX policy matching by signer requires a signer ID")
+		}
+	default:
+		return fmt.Errorf("unknown SGX policy match type \"%s\"", p.MatchBy)
+	}
+
+	if p.SignerID != "" {
+		signerIdBytes, err := hex.DecodeString(p.SignerID)
+		if err != nil {
+			signerIdBytes, err = base64.StdEncoding.DecodeString(p.SignerID)
+			if err != nil {
+				return fmt.Errorf("SGX policy signer ID must be %d bytes hex- or base64-encoded", expectedSignerIdLength)
+			}
+
+			p.SignerID = hex.EncodeToString(signerIdBytes)
+		}
+
+		if len(signerIdBytes) != expectedSignerIdLength {
+			return fmt.Errorf("SGX policy signer ID must be %d bytes", expectedSignerIdLength)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) isTcbStatusAllowed(status tcbstatus.Status) bool {
+	if status == tcbstatus.UpToDate {
+		return true
+	}
+
+	allowedStatuses := p.AllowedTcbStatuses
+	if allowedStatuses == nil {
+		allowedStatuses = DefaultAllowedTcbStatuses
+	}
+
+	return slices.ContainsFunc(allowedStatuses, func(name string) bool {
+		allowed, _ := parseTcbStatus(name)
+		return allowed == status
+	})
+}
+
+// Check applies the debug, security version and TCB status rules to a report
+func (p *Policy) Check(report *attestation.Report) error {
+	if report.Debug && !p.AllowDebug {
+		return ErrDebugEnclave
+	}
+
+	if report.SecurityVersion < p.MinSecurityVersion {
+		return fmt.Errorf("%w: minimum=%d, got=%d", ErrSecurityVersionTooLow, p.MinSecurityVersion, report.SecurityVersion)
+	}
+
+	if !p.isTcbStatusAllowed(report.TCBStatus) {
+		return fmt.Errorf("%w: %s", ErrTcbStatusRejected, report.TCBStatus.String())
+	}
+
+	return nil
+}
+
+func (p *Policy) matchesSigner(report *attestation.Report) bool {
+	signerId, _ := hex.DecodeString(p.SignerID)
+	if !bytes.Equal(report.SignerID, signerId) {
+		return false
+	}
+
+	// ISVPRODID is a 16-bit little-endian number, the rest of the product ID is reserved
+	productId := make([]byte, len(report.ProductID))
+	if len(productId) < 2 {
+		return false
+	}
+	binary.LittleEndian.PutUint16(productId, p.ProductID)
+
+	return bytes.Equal(report.ProductID, productId)
+}
@@ -0,0 +1,176 @@
+package sgx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       Policy
+		wantSignerId string
+		wantErr      bool
+	}{
+		{
+			name:   "zero value",
+			policy: Policy{},
+		},
+		{
+			name: "allowed statuses",
+			policy: Policy{
+				AllowedTcbStatuses: []string{"SWHardeningNeeded", "ConfigurationNeeded"},
+			},
+		},
+		{
+			name: "unknown status",
+			policy: Policy{
+				AllowedTcbStatuses: []string{"Fine"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "signer without signer ID",
+			policy:  Policy{MatchBy: MATCH_BY_SIGNER},
+			wantErr: true,
+		},
+		{
+			name:    "unknown match type",
+			policy:  Policy{MatchBy: "mrenclave"},
+			wantErr: true,
+		},
+		{
+			name: "base64 signer ID",
+			policy: Policy{
+				MatchBy:  MATCH_BY_SIGNER,
+				SignerID: "9H4s7YPOeZFug8XZRRRlc+Z7Vfit98IfkZsrDpb+Dxs=",
+			},
+			wantSignerId: "f47e2ced83ce79916e83c5d945146573e67b55f8adf7c21f919b2b0e96fe0f1b",
+		},
+		{
+			name: "short signer ID",
+			policy: Policy{
+				MatchBy:  MATCH_BY_SIGNER,
+				SignerID: "f47e",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Policy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantSignerId != "" && tt.policy.SignerID != tt.wantSignerId {
+				t.Errorf("Policy.Validate() signer ID = %v, want %v", tt.policy.SignerID, tt.wantSignerId)
+			}
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		report  attestation.Report
+		wantErr error
+	}{
+		{
+			name:   "up to date",
+			policy: Policy{},
+			report: attestation.Report{TCBStatus: tcbstatus.UpToDate},
+		},
+		{
+			name:    "debug rejected",
+			policy:  Policy{},
+			report:  attestation.Report{Debug: true},
+			wantErr: ErrDebugEnclave,
+		},
+		{
+			name:   "debug allowed",
+			policy: Policy{AllowDebug: true},
+			report: attestation.Report{Debug: true},
+		},
+		{
+			name:    "security version too low",
+			policy:  Policy{MinSecurityVersion: 2},
+			report:  attestation.Report{SecurityVersion: 1},
+			wantErr: ErrSecurityVersionTooLow,
+		},
+		{
+			name:   "sw hardening needed by default",
+			policy: Policy{},
+			report: attestation.Report{TCBStatus: tcbstatus.SWHardeningNeeded},
+		},
+		{
+			name:    "out of date by default",
+			policy:  Policy{},
+			report:  attestation.Report{TCBStatus: tcbstatus.OutOfDate},
+			wantErr: ErrTcbStatusRejected,
+		},
+		{
+			name:    "only up to date",
+			policy:  Policy{AllowedTcbStatuses: []string{}},
+			report:  attestation.Report{TCBStatus: tcbstatus.SWHardeningNeeded},
+			wantErr: ErrTcbStatusRejected,
+		},
+		{
+			name:   "only up to date accepts up to date",
+			policy: Policy{AllowedTcbStatuses: []string{"UpToDate"}},
+			report: attestation.Report{TCBStatus: tcbstatus.UpToDate},
+		},
+		{
+			name:   "sw hardening allowed",
+			policy: Policy{AllowedTcbStatuses: []string{"SWHardeningNeeded"}},
+			report: attestation.Report{TCBStatus: tcbstatus.SWHardeningNeeded},
+		},
+		{
+			name:    "out of date rejected",
+			policy:  Policy{AllowedTcbStatuses: []string{"SWHardeningNeeded"}},
+			report:  attestation.Report{TCBStatus: tcbstatus.OutOfDate},
+			wantErr: ErrTcbStatusRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(&tt.report)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Policy.Check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_matchesSigner(t *testing.T) {
+	policy := Policy{
+		MatchBy:   MATCH_BY_SIGNER,
+		SignerID:  "f47e2ced83ce79916e83c5d945146573e67b55f8adf7c21f919b2b0e96fe0f1b",
+		ProductID: 1,
+	}
+
+	signerId := []byte{0xf4, 0x7e, 0x2c, 0xed, 0x83, 0xce, 0x79, 0x91, 0x6e, 0x83, 0xc5, 0xd9, 0x45, 0x14, 0x65, 0x73, 0xe6, 0x7b, 0x55, 0xf8, 0xad, 0xf7, 0xc2, 0x1f, 0x91, 0x9b, 0x2b, 0x0e, 0x96, 0xfe, 0x0f, 0x1b}
+	productId := make([]byte, 16)
+	productId[0] = 1
+
+	if !policy.matchesSigner(&attestation.Report{SignerID: signerId, ProductID: productId}) {
+		t.Errorf("matchesSigner() = false, want true")
+	}
+
+	otherProductId := make([]byte, 16)
+	otherProductId[0] = 2
+
+	if policy.matchesSigner(&attestation.Report{SignerID: signerId, ProductID: otherProductId}) {
+		t.Errorf("matchesSigner() with different product ID = true, want false")
+	}
+
+	if policy.matchesSigner(&attestation.Report{SignerID: make([]byte, 32), ProductID: productId}) {
+		t.Errorf("matchesSigner() with different signer ID = true, want false")
+	}
+}
@@ -4,23 +4,53 @@ import (
 	"encoding/hex"
 	"errors"
 	"log"
+	"slices"
+	"strings"
 
 	"github.com/edgelesssys/ego/attestation"
 	"github.com/edgelesssys/ego/eclient"
 )
 
-func VerifySgxReport(reportBytes []byte, targetUniqueId string) (*attestation.Report, error) {
+var (
+	ErrUniqueIdMismatch = errors.New("report unique ID doesn't match target")
+)
+
+// VerifySgxReport verifies the report signature, applies the policy, then matches the report
+// to the target enclave using the policy's match type. When matching by unique ID, returns the index
+// of the matched target, otherwise returns -1.
+func VerifySgxReport(reportBytes []byte, targetUniqueIds []string, policy *Policy) (*attestation.Report, int, error) {
 	report, err := eclient.VerifyRemoteReport(reportBytes)
+	// a report with a TCB that is not up to date is still a valid report, the policy decides if it's acceptable
+	if err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+		return nil, -1, err
+	}
+
+	if policy == nil {
+		policy = new(Policy)
+	}
+
+	err = policy.Check(&report)
 	if err != nil {
-		return nil, err
+		log.Printf("reporting enclave is rejected by the policy: %s", err)
+		return nil, -1, err
+	}
+
+	if policy.MatchBy == MATCH_BY_SIGNER {
+		if !policy.matchesSigner(&report) {
+			log.Printf("reporting enclave signer doesn't match the expected one, expected=%s/%d, got=%s/%s", policy.SignerID, policy.ProductID, hex.EncodeToString(report.SignerID), hex.EncodeToString(report.ProductID))
+			return nil, -1, ErrSignerMismatch
+		}
+
+		return &report, -1, nil
 	}
 
 	uniqueId := hex.EncodeToString(report.UniqueID)
 
-	if uniqueId != targetUniqueId {
-		log.Printf("reporting enclave unique ID doesn't match the expected one, expected=%s, got=%s", targetUniqueId, uniqueId)
-		return nil, errors.New("report unique ID doesn't match target")
+	targetIdx := slices.Index(targetUniqueIds, uniqueId)
+	if targetIdx == -1 {
+		log.Printf("reporting enclave unique ID doesn't match the expected ones, expected=[%s], got=%s", strings.Join(targetUniqueIds, ", "), uniqueId)
+		return nil, -1, ErrUniqueIdMismatch
 	}
 
-	return &report, nil
+	return &report, targetIdx, nil
 }
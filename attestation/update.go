@@ -0,0 +1,82 @@
+package attestation
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+// size of the Open Enclave report header: version (4 bytes), report type (4 bytes), report size (8 bytes)
+const sgxReportHeaderLen = 16
+
+// VerifiedOracleUpdate is the report and proof data of an oracle update that passed verification
+type VerifiedOracleUpdate struct {
+	Report      *VerifiedReport
+	DecodedData *DecodedProofData
+	Encoded     *EncodedProofData
+}
+
+// TrimReportPadding removes the zero padding that formatting a report as a Leo struct adds to the end of it.
+// Returns the report unchanged if its length cannot be determined
+func TrimReportPadding(reportType string, report []byte) []byte {
+	switch reportType {
+	case TEE_TYPE_SGX:
+		if len(report) < sgxReportHeaderLen {
+			return report
+		}
+
+		size := binary.LittleEndian.Uint64(report[8:sgxReportHeaderLen])
+		if size > uint64(len(report)-sgxReportHeaderLen) {
+			return report
+		}
+
+		return report[:sgxReportHeaderLen+size]
+
+	case TEE_TYPE_NITRO:
+		// the attestation document is a single CBOR item
+		var doc cbor.RawMessage
+		rest, err := cbor.UnmarshalFirst(report, &doc)
+		if err != nil {
+			return report
+		}
+
+		return report[:len(report)-len(rest)]
+	}
+
+	return report
+}
+
+// VerifyOracleUpdate verifies an oracle update as a Leo program receives it: the report of the enclave
+// and the proof data that the enclave committed to. The proof data must decode, and its hash must match the
+// report's userData. Freshness is not checked, the update can be verified at any time after it was made.
+func VerifyOracleUpdate(aleoSession aleo_wrapper.Session, reportType string, report []byte, proofData []byte, measurements []MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*VerifiedOracleUpdate, error) {
+	decodedData, err := DecodeProofData(proofData)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := HashProofData(aleoSession, proofData)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedReport, err := VerifyReport(reportType, TrimReportPadding(reportType, report), "", measurements, sgxPolicy, verificationTime)
+	if err != nil {
+		return nil, err
+	}
+
+	err = MatchUserData(encoded.Hash, verifiedReport.UserData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifiedOracleUpdate{
+		Report:      verifiedReport,
+		DecodedData: decodedData,
+		Encoded:     encoded,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestTrimReportPadding(t *testing.T) {
+	sgxReport := make([]byte, sgxReportHeaderLen+4)
+	binary.LittleEndian.PutUint64(sgxReport[8:], 4)
+	copy(sgxReport[sgxReportHeaderLen:], []byte{1, 2, 3, 4})
+
+	sgxReportTooLong := bytes.Clone(sgxReport)
+	binary.LittleEndian.PutUint64(sgxReportTooLong[8:], 100)
+
+	nitroReport, err := cbor.Marshal([]interface{}{[]byte{1, 2}, map[string]int{"a": 1}, []byte{0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	padding := make([]byte, 32)
+
+	tests := []struct {
+		name       string
+		reportType string
+		report     []byte
+		want       []byte
+	}{
+		{
+			name:       "sgx",
+			reportType: TEE_TYPE_SGX,
+			report:     append(bytes.Clone(sgxReport), padding...),
+			want:       sgxReport,
+		},
+		{
+			name:       "sgx without padding",
+			reportType: TEE_TYPE_SGX,
+			report:     sgxReport,
+			want:       sgxReport,
+		},
+		{
+			name:       "sgx size past the end",
+			reportType: TEE_TYPE_SGX,
+			report:     sgxReportTooLong,
+			want:       sgxReportTooLong,
+		},
+		{
+			name:       "sgx too short",
+			reportType: TEE_TYPE_SGX,
+			report:     []byte{1, 2, 3},
+			want:       []byte{1, 2, 3},
+		},
+		{
+			name:       "nitro",
+			reportType: TEE_TYPE_NITRO,
+			report:     append(bytes.Clone(nitroReport), padding...),
+			want:       nitroReport,
+		},
+		{
+			name:       "nitro not cbor",
+			reportType: TEE_TYPE_NITRO,
+			report:     []byte{0xff, 0xff},
+			want:       []byte{0xff, 0xff},
+		},
+		{
+			name:       "unknown type",
+			reportType: "tdx",
+			report:     padding,
+			want:       padding,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrimReportPadding(tt.reportType, tt.report); !bytes.Equal(got, tt.want) {
+				t.Errorf("TrimReportPadding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+var ErrInvalidAttestationValue = errors.New("invalid attestation value")
+
+// Decimal is an exact decimal number equal to Unscaled / 10^Scale
+type Decimal struct {
+	Unscaled *big.Int
+	// number of digits after the decimal point
+	Scale uint
+}
+
+// String returns the decimal with exactly Scale digits after the decimal point
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+
+	sign := ""
+	if d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+
+	if d.Scale == 0 {
+		return sign + digits
+	}
+
+	scale := int(d.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// AttestationValue is the attestation data decoded according to its encoding options.
+// Only one of Int, Decimal, and Text is set depending on Type
+type AttestationValue struct {
+	// one of the encoding.ENCODING_OPTION_ values
+	Type string
+
+	Int     *big.Int
+	Decimal *Decimal
+	Text    string
+
+	// true if the attestation data string in DecodedProofData doesn't represent the value exactly
+	PrecisionLoss bool
+}
+
+// JSON form of AttestationValue, numbers are strings so that clients can parse them without losing precision
+type attestationValueJSON struct {
+	Type          string `json:"type"`
+	Value         string `json:"value"`
+	Scale         *uint  `json:"scale,omitempty"`
+	PrecisionLoss bool   `json:"precisionLoss"`
+}
+
+func (v *AttestationValue) MarshalJSON() ([]byte, error) {
+	aux := &attestationValueJSON{
+		Type:          v.Type,
+		PrecisionLoss: v.PrecisionLoss,
+	}
+
+	switch {
+	case v.Int != nil:
+		aux.Value = v.Int.String()
+	case v.Decimal != nil:
+		aux.Value = v.Decimal.String()
+		aux.Scale = &v.Decimal.Scale
+	default:
+		aux.Value = v.Text
+	}
+
+	return json.Marshal(aux)
+}
+
+func (v *AttestationValue) UnmarshalJSON(data []byte) error {
+	aux := new(attestationValueJSON)
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	*v = AttestationValue{
+		Type:          aux.Type,
+		PrecisionLoss: aux.PrecisionLoss,
+	}
+
+	switch aux.Type {
+	case encoding.ENCODING_OPTION_INT:
+		num, ok := new(big.Int).SetString(aux.Value, 10)
+		if !ok {
+			return ErrInvalidAttestationValue
+		}
+		v.Int = num
+	case encoding.ENCODING_OPTION_FLOAT:
+		if aux.Scale == nil {
+			return ErrInvalidAttestationValue
+		}
+		num, ok := new(big.Int).SetString(strings.Replace(aux.Value, ".", "", 1), 10)
+		if !ok {
+			return ErrInvalidAttestationValue
+		}
+		v.Decimal = &Decimal{Unscaled: num, Scale: *aux.Scale}
+	default:
+		v.Text = aux.Value
+	}
+
+	return nil
+}
+
+// decodes the typed attestation value from the attestation data bytes. Numbers are encoded as 8 little-endian bytes,
+// floats are multiplied by 10^precision before encoding. attestationData is the string form of the value that
+// is checked for precision loss
+func decodeAttestationValue(buf []byte, options *encoding.EncodingOptions, attestationData string) *AttestationValue {
+	value := &AttestationValue{
+		Type: options.Value,
+	}
+
+	switch options.Value {
+	case encoding.ENCODING_OPTION_INT:
+		value.Int = new(big.Int).SetUint64(encoding.BytesToNumber(buf[:encoding.TARGET_ALIGNMENT/2]))
+		value.PrecisionLoss = value.Int.String() != attestationData
+	case encoding.ENCODING_OPTION_FLOAT:
+		value.Decimal = &Decimal{
+			Unscaled: new(big.Int).SetUint64(encoding.BytesToNumber(buf[:encoding.TARGET_ALIGNMENT/2])),
+			Scale:    options.Precision,
+		}
+		value.PrecisionLoss = value.Decimal.String() != attestationData
+	default:
+		value.Text = attestationData
+	}
+
+	return value
+}
@@ -0,0 +1,130 @@
+package attestation
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+func TestDecimal_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		unscaled int64
+		scale    uint
+		want     string
+	}{
+		{name: "integer", unscaled: 42, scale: 0, want: "42"},
+		{name: "fraction", unscaled: 4250, scale: 2, want: "42.50"},
+		{name: "leading zeroes", unscaled: 5, scale: 3, want: "0.005"},
+		{name: "zero", unscaled: 0, scale: 2, want: "0.00"},
+		{name: "negative", unscaled: -5, scale: 1, want: "-0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Decimal{Unscaled: big.NewInt(tt.unscaled), Scale: tt.scale}
+			if got := d.String(); got != tt.want {
+				t.Errorf("Decimal.String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeAttestationValue(t *testing.T) {
+	encodeNumber := func(num uint64) []byte {
+		buf := make([]byte, encoding.TARGET_ALIGNMENT)
+		copy(buf, encoding.NumberToBytes(num))
+		return buf
+	}
+
+	tests := []struct {
+		name              string
+		attestationData   string
+		options           encoding.EncodingOptions
+		wantValue         string
+		wantPrecisionLoss bool
+	}{
+		{
+			name:            "string",
+			attestationData: "hello",
+			options:         encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_STRING},
+			wantValue:       "hello",
+		},
+		{
+			name:            "int",
+			attestationData: "18446744073709551615",
+			options:         encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_INT},
+			wantValue:       "18446744073709551615",
+		},
+		{
+			name:            "float",
+			attestationData: "42.50",
+			options:         encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_FLOAT, Precision: 2},
+			wantValue:       "42.50",
+		},
+		{
+			name:              "float that doesn't fit in float64",
+			attestationData:   "92233720368547758.09",
+			options:           encoding.EncodingOptions{Value: encoding.ENCODING_OPTION_FLOAT, Precision: 2},
+			wantValue:         "92233720368547758.09",
+			wantPrecisionLoss: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := encoding.EncodeAttestationData(tt.attestationData, &tt.options)
+			if tt.wantPrecisionLoss {
+				// the encoder refuses such values, so they're encoded directly
+				buf = encodeNumber(9223372036854775809)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			legacy, err := encoding.DecodeAttestationData(buf, len(tt.attestationData), &tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.options.Value == encoding.ENCODING_OPTION_FLOAT {
+				legacy = cleanupDecodedFloat(legacy, tt.options.Precision)
+			}
+
+			value := decodeAttestationValue(buf, &tt.options, legacy)
+			if value.Type != tt.options.Value {
+				t.Errorf("decodeAttestationValue() type = %s, want %s", value.Type, tt.options.Value)
+			}
+			if value.PrecisionLoss != tt.wantPrecisionLoss {
+				t.Errorf("decodeAttestationValue() precision loss = %v, want %v, legacy value = %s", value.PrecisionLoss, tt.wantPrecisionLoss, legacy)
+			}
+
+			var got string
+			switch {
+			case value.Int != nil:
+				got = value.Int.String()
+			case value.Decimal != nil:
+				got = value.Decimal.String()
+			default:
+				got = value.Text
+			}
+			if got != tt.wantValue {
+				t.Errorf("decodeAttestationValue() value = %s, want %s", got, tt.wantValue)
+			}
+
+			// values survive a JSON round trip without losing precision
+			msg, err := json.Marshal(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(AttestationValue)
+			if err = json.Unmarshal(msg, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, value) {
+				t.Errorf("JSON round trip = %+v, want %+v", decoded, value)
+			}
+		})
+	}
+}
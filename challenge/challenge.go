@@ -0,0 +1,48 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// size of an issued nonce in bytes
+const NonceSize = 32
+
+// Challenger issues single-use, expiring nonces and redeems them when a report that carries the nonce is verified
+type Challenger struct {
+	store Store
+	ttl   time.Duration
+}
+
+func NewChallenger(store Store, ttl time.Duration) *Challenger {
+	return &Challenger{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// Issue creates a new hex-encoded random nonce for the client, e.g. its IP address, and returns it with its expiration time
+func (c *Challenger) Issue(client string) (string, time.Time, error) {
+	buf := make([]byte, NonceSize)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	nonce := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(c.ttl)
+
+	err = c.store.Add(nonce, client, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return nonce, expiresAt, nil
+}
+
+// Redeem checks that the hex-encoded nonce was issued by this challenger, hasn't expired, and wasn't used before.
+// A redeemed nonce cannot be redeemed again.
+func (c *Challenger) Redeem(nonce string) error {
+	return c.store.Consume(nonce, time.Now())
+}
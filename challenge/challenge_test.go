@@ -0,0 +1,186 @@
+package challenge
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChallenger(t *testing.T) {
+	challenger := NewChallenger(NewMemoryStore(0, 0), time.Minute)
+
+	nonce, expiresAt, err := challenger.Issue("client")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if len(nonce) != NonceSize*2 {
+		t.Errorf("Issue() nonce length = %d, want %d", len(nonce), NonceSize*2)
+	}
+
+	if time.Until(expiresAt) > time.Minute || time.Until(expiresAt) < 0 {
+		t.Errorf("Issue() expiresAt = %v, want within a minute", expiresAt)
+	}
+
+	if err := challenger.Redeem(nonce); err != nil {
+		t.Errorf("Redeem() error = %v", err)
+	}
+
+	if err := challenger.Redeem(nonce); !errors.Is(err, ErrNonceUsed) {
+		t.Errorf("Redeem() of a used nonce error = %v, want %v", err, ErrNonceUsed)
+	}
+
+	if err := challenger.Redeem("e14232e0b0f4311805dd11eebf31d04abeddbbb903bcd2c7c1ef5df798e8c8ee"); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("Redeem() of an unknown nonce error = %v, want %v", err, ErrUnknownNonce)
+	}
+}
+
+func TestChallenger_RedeemConcurrent(t *testing.T) {
+	challenger := NewChallenger(NewMemoryStore(0, 0), time.Minute)
+
+	nonce, _, err := challenger.Issue("client")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if challenger.Redeem(nonce) == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if redeemed != 1 {
+		t.Errorf("nonce was redeemed %d times, want 1", redeemed)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		consumeAt time.Time
+		wantErr   error
+	}{
+		{
+			name:      "valid",
+			expiresAt: now.Add(time.Minute),
+			consumeAt: now,
+		},
+		{
+			name:      "expired",
+			expiresAt: now.Add(time.Minute),
+			consumeAt: now.Add(2 * time.Minute),
+			wantErr:   ErrNonceExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore(0, 0)
+
+			if err := store.Add("nonce", "client", tt.expiresAt); err != nil {
+				t.Fatalf("MemoryStore.Add() error = %v", err)
+			}
+
+			if err := store.Add("nonce", "client", tt.expiresAt); !errors.Is(err, ErrNonceDuplicate) {
+				t.Errorf("MemoryStore.Add() of a duplicate error = %v, want %v", err, ErrNonceDuplicate)
+			}
+
+			if err := store.Consume("nonce", tt.consumeAt); !errors.Is(err, tt.wantErr) {
+				t.Errorf("MemoryStore.Consume() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_RemovesExpired(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+
+	store.Add("old", "client", time.Now().Add(-time.Second))
+	store.Add("new", "client", time.Now().Add(time.Minute))
+
+	if len(store.entries) != 1 {
+		t.Errorf("MemoryStore has %d entries, want 1", len(store.entries))
+	}
+
+	if err := store.Consume("old", time.Now()); !errors.Is(err, ErrUnknownNonce) {
+		t.Errorf("MemoryStore.Consume() of a removed nonce error = %v, want %v", err, ErrUnknownNonce)
+	}
+}
+
+func TestMemoryStore_MaxEntries(t *testing.T) {
+	store := NewMemoryStore(2, 0)
+
+	store.Add("expired", "client", time.Now().Add(-time.Second))
+	if err := store.Add("a", "client", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("MemoryStore.Add() error = %v", err)
+	}
+	if err := store.Add("b", "client", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("MemoryStore.Add() after removing an expired nonce error = %v", err)
+	}
+
+	// a used nonce is kept until it expires
+	if err := store.Consume("a", time.Now()); err != nil {
+		t.Fatalf("MemoryStore.Consume() error = %v", err)
+	}
+
+	if err := store.Add("c", "client", time.Now().Add(time.Minute)); !errors.Is(err, ErrTooManyNonces) {
+		t.Errorf("MemoryStore.Add() over the limit error = %v, want %v", err, ErrTooManyNonces)
+	}
+}
+
+func TestMemoryStore_RemovesExpiredInOrder(t *testing.T) {
+	store := NewMemoryStore(0, 0)
+	now := time.Now()
+
+	// added out of expiration order
+	store.Add("late", "client", now.Add(time.Minute))
+	store.Add("early", "client", now.Add(-2*time.Second))
+	store.Add("middle", "client", now.Add(-time.Second))
+
+	store.Add("new", "client", now.Add(time.Minute))
+
+	if len(store.entries) != 2 || store.entries["late"] == nil || store.entries["new"] == nil {
+		t.Errorf("MemoryStore has entries %v, want late and new", store.entries)
+	}
+
+	if store.expirations.Len() != 2 || store.clients["client"] != 2 {
+		t.Errorf("MemoryStore has %d expirations and %d client nonces, want 2 and 2", store.expirations.Len(), store.clients["client"])
+	}
+}
+
+func TestMemoryStore_MaxPerClient(t *testing.T) {
+	store := NewMemoryStore(0, 2)
+
+	// an expired nonce doesn't count once it's removed
+	store.Add("expired", "a", time.Now().Add(-time.Second))
+	for _, nonce := range []string{"a1", "a2"} {
+		if err := store.Add(nonce, "a", time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("MemoryStore.Add() error = %v", err)
+		}
+	}
+
+	if err := store.Add("a3", "a", time.Now().Add(time.Minute)); !errors.Is(err, ErrClientQuotaExceeded) {
+		t.Errorf("MemoryStore.Add() over the client limit error = %v, want %v", err, ErrClientQuotaExceeded)
+	}
+
+	// other clients are not affected
+	if err := store.Add("b1", "b", time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("MemoryStore.Add() for another client error = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package challenge
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownNonce        = errors.New("nonce was not issued by this server")
+	ErrNonceExpired        = errors.New("nonce has expired")
+	ErrNonceUsed           = errors.New("nonce has already been used")
+	ErrNonceDuplicate      = errors.New("nonce already exists")
+	ErrTooManyNonces       = errors.New("too many outstanding nonces")
+	ErrClientQuotaExceeded = errors.New("too many outstanding nonces for the client")
+)
+
+// Store keeps issued challenge nonces. Implementations must be goroutine-safe.
+type Store interface {
+	// Add stores a new nonce issued to the client that can be used until expiresAt
+	Add(nonce, client string, expiresAt time.Time) error
+	// Consume atomically marks the nonce as used. Returns ErrUnknownNonce, ErrNonceExpired or ErrNonceUsed
+	// if the nonce cannot be used.
+	Consume(nonce string, now time.Time) error
+}
+
+type memoryEntry struct {
+	client    string
+	expiresAt time.Time
+	used      bool
+}
+
+type expiration struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// min-heap of nonce expirations, the nonce that expires first is at the top
+type expirationQueue []expiration
+
+func (q expirationQueue) Len() int           { return len(q) }
+func (q expirationQueue) Less(i, j int) bool { return q[i].expiresAt.Before(q[j].expiresAt) }
+func (q expirationQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *expirationQueue) Push(x any) {
+	*q = append(*q, x.(expiration))
+}
+
+func (q *expirationQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// MemoryStore is an in-memory Store. Expired nonces are removed when new nonces are added.
+// Used nonces count as outstanding until they expire.
+type MemoryStore struct {
+	mu          sync.Mutex
+	entries     map[string]*memoryEntry
+	expirations expirationQueue
+	// number of outstanding nonces of every client that has any
+	clients      map[string]int
+	maxEntries   int
+	maxPerClient int
+}
+
+// NewMemoryStore creates a store that keeps at most maxEntries nonces, and at most maxPerClient nonces of one client.
+// Zero limits allow any number of nonces
+func NewMemoryStore(maxEntries, maxPerClient int) *MemoryStore {
+	return &MemoryStore{
+		entries:      make(map[string]*memoryEntry),
+		clients:      make(map[string]int),
+		maxEntries:   maxEntries,
+		maxPerClient: maxPerClient,
+	}
+}
+
+func (s *MemoryStore) removeExpired(now time.Time) {
+	for len(s.expirations) > 0 && now.After(s.expirations[0].expiresAt) {
+		expired := heap.Pop(&s.expirations).(expiration)
+
+		entry := s.entries[expired.nonce]
+		delete(s.entries, expired.nonce)
+
+		s.clients[entry.client]--
+		if s.clients[entry.client] == 0 {
+			delete(s.clients, entry.client)
+		}
+	}
+}
+
+func (s *MemoryStore) Add(nonce, client string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired(time.Now())
+
+	if _, exists := s.entries[nonce]; exists {
+		return ErrNonceDuplicate
+	}
+
+	if s.maxPerClient > 0 && s.clients[client] >= s.maxPerClient {
+		return ErrClientQuotaExceeded
+	}
+
+	if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		return ErrTooManyNonces
+	}
+
+	s.entries[nonce] = &memoryEntry{client: client, expiresAt: expiresAt}
+	s.clients[client]++
+	heap.Push(&s.expirations, expiration{nonce: nonce, expiresAt: expiresAt})
+
+	return nil
+}
+func (s *MemoryStore) Consume(nonce string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.entries[nonce]
+	if !exists {
+		return ErrUnknownNonce
+	}
+
+	if now.After(entry.expiresAt) {
+		return ErrNonceExpired
+	}
+
+	if entry.used {
+		return ErrNonceUsed
+	}
+
+	// keep used nonces until they expire so that a replayed report is recognized
+	entry.used = true
+
+	return nil
+}
@@ -7,58 +7,146 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/liveCheck"
 )
 
 const expectedUniqueIdLength = 32
 const expectedPcrValueLength = 48
 
+// default lifetime of a /nonce challenge in seconds
+const defaultChallengeTtl = 300
+
+// default maximum number of issued nonces that haven't expired
+const defaultMaxOutstandingChallenges = 10000
+
+// default maximum number of issued nonces of one client that haven't expired
+const defaultMaxOutstandingClientChallenges = 100
+
+// default maximum number of verification history records
+const defaultHistoryMaxRecords = 100000
+
+// default time that verification history records are kept in hours, 30 days
+const defaultHistoryMaxAgeHours = 720
+
+// history limit that keeps records without a limit
+const HistoryUnlimited = -1
+
+// default time between checks for new blocks by the watcher in seconds
+const defaultWatcherPollInterval = 10
+
+// default time between checks of the live contract's measurements in seconds
+const defaultLiveCheckRefreshInterval = 300
+
+// default time budget for verifying one report in milliseconds
+const defaultVerifyReportBudget = 1000
+
+// default maximum number of reports in one /verify request
+const defaultVerifyMaxReports = 100
+
+// time for reading the request and writing the response, added to the verification budget in the write timeout
+const baseWriteTimeout = 5 * time.Second
+
+// label of the measurement set created from "uniqueIdTarget" and "pcrValuesTarget"
+const TargetMeasurementsLabel = "target"
+
 type Configuration struct {
-	Port            uint16   `json:"port"`
-	UseTls          bool     `json:"useTls"`
-	TlsKeyFile      string   `json:"tlsKey"`
-	TlsCertFile     string   `json:"tlsCert"`
-	UniqueIdTarget  string   `json:"uniqueIdTarget"`
-	PcrValuesTarget []string `json:"pcrValuesTarget"`
-	LiveCheck       struct {
-		Skip         bool   `json:"skip"`
-		ApiBaseUrl   string `json:"apiBaseUrl"`
-		ContractName string `json:"contractName"`
+	Port                uint16                       `json:"port"`
+	UseTls              bool                         `json:"useTls"`
+	TlsKeyFile          string                       `json:"tlsKey"`
+	TlsCertFile         string                       `json:"tlsCert"`
+	UniqueIdTarget      string                       `json:"uniqueIdTarget"`
+	PcrValuesTarget     []string                     `json:"pcrValuesTarget"`
+	Measurements        []attestation.MeasurementSet `json:"measurements"`
+	VerifyWorkers       int                          `json:"verifyWorkers"`
+	AleoSessionPoolSize int                          `json:"aleoSessionPoolSize"`
+	Freshness           attestation.FreshnessPolicy  `json:"freshness"`
+	SgxPolicy           sgx.Policy                   `json:"sgxPolicy"`
+	PriceFeeds          []attestation.PriceFeed      `json:"priceFeeds"`
+	// time budget for verifying one report, used to derive the write timeout and the wait for Aleo sessions
+	VerifyReportBudgetMs uint64 `json:"verifyReportBudgetMs"`
+	VerifyMaxReports     int    `json:"verifyMaxReports"`
+	// derived from the verification budget if zero
+	WriteTimeoutSeconds uint64 `json:"writeTimeoutSeconds"`
+	Challenges          struct {
+		TtlSeconds uint64 `json:"ttlSeconds"`
+		Require    bool   `json:"require"`
+		// maximum number of issued nonces that haven't expired
+		MaxOutstanding int `json:"maxOutstanding"`
+		// maximum number of issued nonces of one client that haven't expired
+		MaxOutstandingPerClient int `json:"maxOutstandingPerClient"`
+	} `json:"challenges"`
+	LiveCheck struct {
+		Skip       bool   `json:"skip"`
+		ApiBaseUrl string `json:"apiBaseUrl"`
+		// used in order after apiBaseUrl when it fails
+		ApiFallbackUrls        []string `json:"apiFallbackUrls"`
+		ApiMaxAttempts         int      `json:"apiMaxAttempts"`
+		ApiTimeoutSeconds      uint64   `json:"apiTimeoutSeconds"`
+		ApiMaxRetryWaitSeconds uint64   `json:"apiMaxRetryWaitSeconds"`
+		ContractName           string   `json:"contractName"`
+		RefreshIntervalSeconds uint64   `json:"refreshIntervalSeconds"`
+		// what to do when the contract's measurements change, one of the liveCheck.POLICY_ values
+		OnChange string `json:"onChange"`
+		// names of the contract's oracle update transitions, the defaults are used if empty
+		UpdateFunctions contract.UpdateFunctions `json:"updateFunctions"`
 	} `json:"liveCheck"`
+	Watcher struct {
+		Enable              bool   `json:"enable"`
+		PollIntervalSeconds uint64 `json:"pollIntervalSeconds"`
+		CursorFile          string `json:"cursorFile"`
+		StartHeight         uint64 `json:"startHeight"`
+	} `json:"watcher"`
+	History struct {
+		// database file for the verification history, the history is kept in memory if empty
+		File string `json:"file"`
+		// the oldest records are removed when there are more records, or when they are older.
+		// HistoryUnlimited keeps records without the limit, zero is the default limit
+		MaxRecords  int `json:"maxRecords"`
+		MaxAgeHours int `json:"maxAgeHours"`
+	} `json:"history"`
 }
 
-func validateAndNormalizeUniqueId(conf *Configuration) error {
+func validateAndNormalizeUniqueId(uniqueId *string, key string) error {
 	// check the unique ID for correctness, if it's base64 then convert to hex
-	if len(conf.UniqueIdTarget) != 0 {
+	if len(*uniqueId) != 0 {
 		var uniqueIdBytes []byte
 		var err error
 
-		uniqueIdBytes, err = hex.DecodeString(conf.UniqueIdTarget)
+		uniqueIdBytes, err = hex.DecodeString(*uniqueId)
 		isHex := err == nil
 
 		// now try decoding as base64
 		if !isHex {
-			uniqueIdBytes, err = base64.StdEncoding.DecodeString(conf.UniqueIdTarget)
+			uniqueIdBytes, err = base64.StdEncoding.DecodeString(*uniqueId)
 			if err != nil {
-				log.Printf("config: invalid SGX Unique ID: \"%s\"\n", conf.UniqueIdTarget)
-				return fmt.Errorf("config \"uniqueIdTarget\" must be %d bytes hex- or base64-encoded", expectedUniqueIdLength)
+				log.Printf("config: invalid SGX Unique ID: \"%s\"\n", *uniqueId)
+				return fmt.Errorf("config \"%s\" must be %d bytes hex- or base64-encoded", key, expectedUniqueIdLength)
 			}
 
 			// convert the unique ID to a hex string
-			conf.UniqueIdTarget = hex.EncodeToString(uniqueIdBytes)
+			*uniqueId = hex.EncodeToString(uniqueIdBytes)
 		}
 
 		if len(uniqueIdBytes) != expectedUniqueIdLength {
-			log.Printf("config: invalid SGX Unique ID: \"%s\"\n", conf.UniqueIdTarget)
-			return fmt.Errorf("config \"uniqueIdTarget\" must be %d bytes", expectedUniqueIdLength)
+			log.Printf("config: invalid SGX Unique ID: \"%s\"\n", *uniqueId)
+			return fmt.Errorf("config \"%s\" must be %d bytes", key, expectedUniqueIdLength)
 		}
 	}
 
 	return nil
 }
 
-func validateAndNormalizePcrValues(conf *Configuration) error {
-	for pcrIdx, pcr := range conf.PcrValuesTarget {
+func validateAndNormalizePcrValues(pcrValues []string, key string) error {
+	for pcrIdx, pcr := range pcrValues {
 		var pcrBytes []byte
 		var err error
 
@@ -70,22 +158,79 @@ func validateAndNormalizePcrValues(conf *Configuration) error {
 			pcrBytes, err = base64.StdEncoding.DecodeString(pcr)
 			if err != nil {
 				log.Printf("config: invalid Nitro PCR value: \"%s\"\n", pcr)
-				return fmt.Errorf("config \"pcrValuesTarget\" values must be %d bytes hex- or base64-encoded", expectedPcrValueLength)
+				return fmt.Errorf("config \"%s\" values must be %d bytes hex- or base64-encoded", key, expectedPcrValueLength)
 			}
 
 			// convert the PCR value to a hex string
-			conf.PcrValuesTarget[pcrIdx] = hex.EncodeToString(pcrBytes)
+			pcrValues[pcrIdx] = hex.EncodeToString(pcrBytes)
 		}
 
 		if len(pcrBytes) != expectedPcrValueLength {
 			log.Printf("config: invalid Nitro PCR value: \"%s\"\n", pcr)
-			return fmt.Errorf("config \"pcrValuesTarget\" values must be %d bytes", expectedPcrValueLength)
+			return fmt.Errorf("config \"%s\" values must be %d bytes", key, expectedPcrValueLength)
 		}
 	}
 
 	return nil
 }
 
+func validateAndNormalizeMeasurements(conf *Configuration) error {
+	labels := make(map[string]bool, len(conf.Measurements))
+
+	for idx := range conf.Measurements {
+		m := &conf.Measurements[idx]
+
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("config \"measurements\" is invalid: %w", err)
+		}
+
+		if m.Label == TargetMeasurementsLabel {
+			return fmt.Errorf("config \"measurements\" label \"%s\" is reserved", TargetMeasurementsLabel)
+		}
+
+		if labels[m.Label] {
+			return fmt.Errorf("config \"measurements\" label \"%s\" is used more than once", m.Label)
+		}
+		labels[m.Label] = true
+
+		if m.Source == "" {
+			m.Source = attestation.MEASUREMENTS_SOURCE_CONFIG
+		}
+
+		err := validateAndNormalizeUniqueId(&m.UniqueID, fmt.Sprintf("measurements[%d].uniqueId", idx))
+		if err != nil {
+			return err
+		}
+
+		err = validateAndNormalizePcrValues(m.PcrValues, fmt.Sprintf("measurements[%d].pcrValues", idx))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddTargetMeasurements adds "uniqueIdTarget" and "pcrValuesTarget" as the first measurement set
+// if at least one of them is configured
+func (conf *Configuration) AddTargetMeasurements(source string) {
+	target := attestation.MeasurementSet{
+		Label:    TargetMeasurementsLabel,
+		Source:   source,
+		UniqueID: conf.UniqueIdTarget,
+	}
+
+	if len(conf.PcrValuesTarget) == 3 {
+		target.PcrValues = conf.PcrValuesTarget
+	}
+
+	if !target.HasSgx() && !target.HasNitro() {
+		return
+	}
+
+	conf.Measurements = slices.Insert(conf.Measurements, 0, target)
+}
+
 func LoadConfig(confContent []byte) (*Configuration, error) {
 	conf := new(Configuration)
 
@@ -102,15 +247,174 @@ func LoadConfig(confContent []byte) (*Configuration, error) {
 		conf.LiveCheck.ContractName = conf.LiveCheck.ContractName + ".aleo"
 	}
 
-	err = validateAndNormalizeUniqueId(conf)
+	if conf.LiveCheck.ApiMaxAttempts < 0 {
+		return nil, errors.New("config \"liveCheck.apiMaxAttempts\" cannot be negative")
+	}
+
+	if conf.LiveCheck.RefreshIntervalSeconds == 0 {
+		conf.LiveCheck.RefreshIntervalSeconds = defaultLiveCheckRefreshInterval
+	}
+
+	if conf.LiveCheck.OnChange == "" {
+		conf.LiveCheck.OnChange = liveCheck.POLICY_DEGRADE
+	}
+
+	err = liveCheck.ValidatePolicy(conf.LiveCheck.OnChange)
+	if err != nil {
+		return nil, fmt.Errorf("config \"liveCheck.onChange\" is invalid: %w", err)
+	}
+
+	if conf.VerifyWorkers < 0 {
+		return nil, errors.New("config \"verifyWorkers\" cannot be negative")
+	}
+
+	// verify reports using all available CPUs by default
+	if conf.VerifyWorkers == 0 {
+		conf.VerifyWorkers = runtime.NumCPU()
+	}
+
+	if conf.VerifyReportBudgetMs == 0 {
+		conf.VerifyReportBudgetMs = defaultVerifyReportBudget
+	}
+
+	if conf.VerifyMaxReports < 0 {
+		return nil, errors.New("config \"verifyMaxReports\" cannot be negative")
+	}
+
+	if conf.VerifyMaxReports == 0 {
+		conf.VerifyMaxReports = defaultVerifyMaxReports
+	}
+
+	if conf.Challenges.TtlSeconds == 0 {
+		conf.Challenges.TtlSeconds = defaultChallengeTtl
+	}
+
+	if conf.Challenges.MaxOutstanding < 0 {
+		return nil, errors.New("config \"challenges.maxOutstanding\" cannot be negative")
+	}
+
+	if conf.Challenges.MaxOutstanding == 0 {
+		conf.Challenges.MaxOutstanding = defaultMaxOutstandingChallenges
+	}
+
+	if conf.Challenges.MaxOutstandingPerClient < 0 {
+		return nil, errors.New("config \"challenges.maxOutstandingPerClient\" cannot be negative")
+	}
+
+	if conf.Challenges.MaxOutstandingPerClient == 0 {
+		conf.Challenges.MaxOutstandingPerClient = defaultMaxOutstandingClientChallenges
+	}
+
+	if conf.History.MaxRecords < HistoryUnlimited {
+		return nil, fmt.Errorf("config \"history.maxRecords\" must be positive, or %d for no limit", HistoryUnlimited)
+	}
+
+	if conf.History.MaxRecords == 0 {
+		conf.History.MaxRecords = defaultHistoryMaxRecords
+	}
+
+	if conf.History.MaxAgeHours < HistoryUnlimited {
+		return nil, fmt.Errorf("config \"history.maxAgeHours\" must be positive, or %d for no limit", HistoryUnlimited)
+	}
+
+	if conf.History.MaxAgeHours == 0 {
+		conf.History.MaxAgeHours = defaultHistoryMaxAgeHours
+	}
+
+	if conf.Watcher.PollIntervalSeconds == 0 {
+		conf.Watcher.PollIntervalSeconds = defaultWatcherPollInterval
+	}
+
+	if conf.AleoSessionPoolSize < 0 {
+		return nil, errors.New("config \"aleoSessionPoolSize\" cannot be negative")
+	}
+
+	if conf.AleoSessionPoolSize == 0 {
+		conf.AleoSessionPoolSize = runtime.NumCPU()
+	}
+
+	err = validateAndNormalizeUniqueId(&conf.UniqueIdTarget, "uniqueIdTarget")
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAndNormalizePcrValues(conf.PcrValuesTarget, "pcrValuesTarget")
 	if err != nil {
 		return nil, err
 	}
 
-	err = validateAndNormalizePcrValues(conf)
+	err = validateAndNormalizeMeasurements(conf)
 	if err != nil {
 		return nil, err
 	}
 
+	err = conf.SgxPolicy.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("config \"sgxPolicy\" is invalid: %w", err)
+	}
+
+	// use the oracle's price feeds if there are none configured
+	if conf.PriceFeeds == nil {
+		conf.PriceFeeds = attestation.DefaultPriceFeeds()
+	}
+
+	err = attestation.ValidatePriceFeeds(conf.PriceFeeds)
+	if err != nil {
+		return nil, fmt.Errorf("config \"priceFeeds\" is invalid: %w", err)
+	}
+
 	return conf, nil
 }
+
+// NodeClientConfig returns the Aleo node API client configuration. Zero values are replaced with the client's defaults
+func (conf *Configuration) NodeClientConfig() contract.ClientConfig {
+	return contract.ClientConfig{
+		Endpoints:      append([]string{conf.LiveCheck.ApiBaseUrl}, conf.LiveCheck.ApiFallbackUrls...),
+		RequestTimeout: time.Duration(conf.LiveCheck.ApiTimeoutSeconds) * time.Second,
+		MaxAttempts:    conf.LiveCheck.ApiMaxAttempts,
+		MaxRetryWait:   time.Duration(conf.LiveCheck.ApiMaxRetryWaitSeconds) * time.Second,
+	}
+}
+
+// HistoryRetention returns the limits of the verification history store. Unlimited config values are zero retention values
+func (conf *Configuration) HistoryRetention() history.Retention {
+	return history.Retention{
+		MaxRecords: max(conf.History.MaxRecords, 0),
+		MaxAge:     time.Duration(max(conf.History.MaxAgeHours, 0)) * time.Hour,
+	}
+}
+
+// VerifyBudget returns the time for verifying the largest allowed /verify request with all workers.
+// A /verify request waits for Aleo sessions for at most this long
+func (conf *Configuration) VerifyBudget() time.Duration {
+	workers := max(min(conf.VerifyWorkers, conf.AleoSessionPoolSize), 1)
+	rounds := (conf.VerifyMaxReports + workers - 1) / workers
+
+	return time.Duration(rounds) * time.Duration(conf.VerifyReportBudgetMs) * time.Millisecond
+}
+
+// DecodeBatchMaxReports returns the maximum number of reports in a /decode/batch request. The reports are verified one
+// after another with one session, so only as many as one worker verifies in the verification budget fit in the write timeout
+func (conf *Configuration) DecodeBatchMaxReports() int {
+	if conf.VerifyReportBudgetMs == 0 {
+		return 1
+	}
+
+	return max(int(conf.VerifyBudget()/(time.Duration(conf.VerifyReportBudgetMs)*time.Millisecond)), 1)
+}
+
+// WriteTimeout returns the HTTP server write timeout. If it's not configured, it fits waiting for
+// Aleo sessions and verifying the largest allowed /verify request
+func (conf *Configuration) WriteTimeout() time.Duration {
+	if conf.WriteTimeoutSeconds != 0 {
+		return time.Duration(conf.WriteTimeoutSeconds) * time.Second
+	}
+
+	return baseWriteTimeout + 2*conf.VerifyBudget()
+}
+
+// NodeApiTimeout returns how long a request waits for the Aleo node API, e.g. for a transaction in /verify/transaction.
+// It leaves half of the write timeout for verifying what was retrieved
+func (conf *Configuration) NodeApiTimeout() time.Duration {
+	return conf.WriteTimeout() / 2
+}
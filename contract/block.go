@@ -0,0 +1,89 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var ErrBlockNotFound = errors.New("contract: block not found")
+
+// status of a transaction that was executed successfully
+const TRANSACTION_STATUS_ACCEPTED = "accepted"
+
+// Transaction in a block with its execution status
+type ConfirmedTransaction struct {
+	Status      string      `json:"status"`
+	Type        string      `json:"type"`
+	Index       int         `json:"index"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// Block as returned by the Aleo node API, only the fields that are needed to find oracle updates
+type Block struct {
+	BlockHash string `json:"block_hash"`
+	Header    struct {
+		Metadata struct {
+			Height uint64 `json:"height"`
+			// block creation time in seconds since epoch
+			Timestamp int64 `json:"timestamp"`
+		} `json:"metadata"`
+	} `json:"header"`
+	Transactions []ConfirmedTransaction `json:"transactions"`
+}
+
+// Time returns the block creation time
+func (b *Block) Time() time.Time {
+	return time.Unix(b.Header.Metadata.Timestamp, 0)
+}
+
+// GetLatestHeight retrieves the height of the latest block from the Aleo node API
+func (c *Client) GetLatestHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+	err := c.getJson(ctx, "/block/height/latest", &height)
+	if err != nil {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+// GetBlock retrieves a block by its height from the Aleo node API
+func (c *Client) GetBlock(ctx context.Context, height uint64) (*Block, error) {
+	block := new(Block)
+	err := c.getJson(ctx, "/block/"+strconv.FormatUint(height, 10), block)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotFound, height)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// GetTransactionTime retrieves the creation time of the block with the transaction from the Aleo node API
+func (c *Client) GetTransactionTime(ctx context.Context, transactionId string) (time.Time, error) {
+	var blockHash string
+	err := c.getJson(ctx, "/find/blockHash/"+url.PathEscape(transactionId), &blockHash)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrTransactionNotFound, transactionId)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	block := new(Block)
+	err = c.getJson(ctx, "/block/"+url.PathEscape(blockHash), block)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrBlockNotFound, blockHash)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return block.Time(), nil
+}
@@ -0,0 +1,552 @@
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the client configuration
+const (
+	DefaultRequestTimeout   = 30 * time.Second
+	DefaultMaxAttempts      = 4
+	DefaultBaseBackoff      = 500 * time.Millisecond
+	DefaultMaxBackoff       = 10 * time.Second
+	DefaultMaxRetryWait     = time.Minute
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+	// blocks with many transactions are the largest responses
+	DefaultMaxResponseSize = 32 << 20
+)
+
+// Circuit breaker states of an endpoint
+const (
+	// requests go to the endpoint
+	BREAKER_CLOSED = "closed"
+	// the endpoint failed too many times in a row, requests go to other endpoints until the cooldown ends
+	BREAKER_OPEN = "open"
+	// the cooldown ended, the next request is a trial and other requests wait for its result.
+	// A failure opens the breaker again, a success closes it
+	BREAKER_HALF_OPEN = "half-open"
+)
+
+var (
+	ErrNoEndpoints          = errors.New("contract: no Aleo node API endpoints configured")
+	ErrEndpointsUnavailable = errors.New("contract: all Aleo node API endpoints are unavailable")
+	ErrResponseTooLarge     = errors.New("contract: Aleo node API response is too large")
+)
+
+type ClientConfig struct {
+	// Aleo node API base URLs in order of preference, e.g. https://api.explorer.provable.com/v1/testnet
+	Endpoints []string
+	// timeout of a single request, DefaultRequestTimeout if zero
+	RequestTimeout time.Duration
+	// number of requests for one call including retries on other endpoints, DefaultMaxAttempts if zero
+	MaxAttempts int
+	// backoff before the first retry, doubles with every retry up to MaxBackoff. Defaults are used if zero
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// longest wait for an endpoint that is rate-limiting or has an open breaker. If every endpoint is unavailable
+	// for longer, the call fails. DefaultMaxRetryWait if zero
+	MaxRetryWait time.Duration
+	// number of consecutive failures that open the breaker of an endpoint, DefaultBreakerThreshold if zero
+	BreakerThreshold int
+	// time that an open breaker rejects requests, DefaultBreakerCooldown if zero
+	BreakerCooldown time.Duration
+	// largest response body in bytes, a larger response is a failure of the endpoint. DefaultMaxResponseSize if zero
+	MaxResponseSize int64
+}
+
+// EndpointMetrics are the request counters and the breaker state of an endpoint
+type EndpointMetrics struct {
+	Url     string `json:"url"`
+	Breaker string `json:"breaker"`
+
+	Requests uint64 `json:"requests"`
+	// requests that the endpoint handled, including 404 and other 4xx responses
+	Successes uint64 `json:"successes"`
+	// network errors, 5xx responses and malformed responses
+	Failures uint64 `json:"failures"`
+	// 429 responses and responses with Retry-After
+	RateLimited     uint64 `json:"rateLimited"`
+	BreakerOpenings uint64 `json:"breakerOpenings"`
+
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// ClientMetrics are the counters of a client. A call is one API method call, which can make several requests
+type ClientMetrics struct {
+	Calls       uint64            `json:"calls"`
+	FailedCalls uint64            `json:"failedCalls"`
+	Retries     uint64            `json:"retries"`
+	Endpoints   []EndpointMetrics `json:"endpoints"`
+}
+
+type endpoint struct {
+	baseUrl string
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	// the breaker rejects requests until this time
+	openUntil time.Time
+	// the endpoint asked not to send requests until this time
+	retryAfter time.Time
+	// the trial request of the half-open breaker is in flight until this time, at most until its timeout
+	probeUntil time.Time
+	metrics    EndpointMetrics
+}
+
+// Client is an Aleo node API client with several endpoints. Failed requests are retried with exponential backoff and jitter,
+// moving to the next endpoint after every failure. Every endpoint has a circuit breaker, and Retry-After is respected.
+// The client is goroutine-safe.
+type Client struct {
+	conf       ClientConfig
+	httpClient *http.Client
+	endpoints  []*endpoint
+
+	calls       atomic.Uint64
+	failedCalls atomic.Uint64
+	retries     atomic.Uint64
+
+	// waits before a retry, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewClient(conf ClientConfig) (*Client, error) {
+	if len(conf.Endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = DefaultRequestTimeout
+	}
+	if conf.MaxAttempts == 0 {
+		conf.MaxAttempts = DefaultMaxAttempts
+	}
+	if conf.BaseBackoff == 0 {
+		conf.BaseBackoff = DefaultBaseBackoff
+	}
+	if conf.MaxBackoff == 0 {
+		conf.MaxBackoff = DefaultMaxBackoff
+	}
+	if conf.MaxRetryWait == 0 {
+		conf.MaxRetryWait = DefaultMaxRetryWait
+	}
+	if conf.BreakerThreshold == 0 {
+		conf.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if conf.BreakerCooldown == 0 {
+		conf.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if conf.MaxResponseSize == 0 {
+		conf.MaxResponseSize = DefaultMaxResponseSize
+	}
+
+	endpoints := make([]*endpoint, 0, len(conf.Endpoints))
+	for _, baseUrl := range conf.Endpoints {
+		parsed, err := url.Parse(baseUrl)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("contract: invalid Aleo node API endpoint \"%s\"", baseUrl)
+		}
+
+		baseUrl = strings.TrimSuffix(baseUrl, "/")
+		endpoints = append(endpoints, &endpoint{
+			baseUrl: baseUrl,
+			metrics: EndpointMetrics{Url: baseUrl},
+		})
+	}
+
+	return &Client{
+		conf: conf,
+		httpClient: &http.Client{
+			Timeout: conf.RequestTimeout,
+		},
+		endpoints: endpoints,
+		sleep:     sleepContext,
+	}, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Endpoints returns the base URLs of the endpoints in order of preference
+func (c *Client) Endpoints() []string {
+	result := make([]string, len(c.endpoints))
+	for idx, ep := range c.endpoints {
+		result[idx] = ep.baseUrl
+	}
+
+	return result
+}
+
+// Metrics returns a snapshot of the client's counters
+func (c *Client) Metrics() *ClientMetrics {
+	if c == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	metrics := &ClientMetrics{
+		Calls:       c.calls.Load(),
+		FailedCalls: c.failedCalls.Load(),
+		Retries:     c.retries.Load(),
+		Endpoints:   make([]EndpointMetrics, len(c.endpoints)),
+	}
+
+	for idx, ep := range c.endpoints {
+		ep.mu.Lock()
+		metrics.Endpoints[idx] = ep.metrics
+		metrics.Endpoints[idx].Breaker = ep.breakerState(now, c.conf.BreakerThreshold)
+		ep.mu.Unlock()
+	}
+
+	return metrics
+}
+
+// must be called with the lock held
+func (ep *endpoint) breakerState(now time.Time, threshold int) string {
+	if ep.consecutiveFailures < threshold {
+		return BREAKER_CLOSED
+	}
+
+	if now.Before(ep.openUntil) {
+		return BREAKER_OPEN
+	}
+
+	return BREAKER_HALF_OPEN
+}
+
+// returns the earliest time when the endpoint accepts requests. While the trial request of a half-open breaker
+// is in flight, the endpoint is checked again after pollInterval
+func (ep *endpoint) availableAt(now time.Time, pollInterval time.Duration) time.Time {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	return ep.availableAtLocked(now, pollInterval)
+}
+
+// must be called with the lock held
+func (ep *endpoint) availableAtLocked(now time.Time, pollInterval time.Duration) time.Time {
+	at := ep.openUntil
+	if ep.retryAfter.After(at) {
+		at = ep.retryAfter
+	}
+
+	if now.Before(ep.probeUntil) {
+		probeAt := now.Add(pollInterval)
+		if ep.probeUntil.Before(probeAt) {
+			probeAt = ep.probeUntil
+		}
+		if probeAt.After(at) {
+			at = probeAt
+		}
+	}
+
+	return at
+}
+
+// reserves the endpoint for a request after waiting for it. Returns false if the breaker was opened during the wait,
+// or if another call is making the trial request of the half-open breaker. Otherwise, if the breaker is half-open,
+// the request is the trial and endProbe must be called after it
+func (ep *endpoint) acquire(now time.Time, threshold int, probeTimeout time.Duration) (probe bool, ok bool) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	state := ep.breakerState(now, threshold)
+	if state == BREAKER_OPEN || now.Before(ep.probeUntil) {
+		return false, false
+	}
+
+	if state != BREAKER_HALF_OPEN {
+		return false, true
+	}
+
+	ep.probeUntil = now.Add(probeTimeout)
+
+	return true, true
+}
+
+// lets other requests go to the endpoint after a trial request. The breaker is closed or opened by the trial's outcome
+func (ep *endpoint) endProbe() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	ep.probeUntil = time.Time{}
+}
+
+func (ep *endpoint) onRequest() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	ep.metrics.Requests++
+}
+
+func (ep *endpoint) onSuccess(now time.Time) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	ep.consecutiveFailures = 0
+	ep.openUntil = time.Time{}
+	ep.metrics.Successes++
+	ep.metrics.LastSuccessAt = &now
+}
+
+func (ep *endpoint) onFailure(now time.Time, err error, threshold int, cooldown time.Duration) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	ep.consecutiveFailures++
+	ep.metrics.Failures++
+	ep.metrics.LastErrorAt = &now
+	ep.metrics.LastError = err.Error()
+
+	// a failed trial in the half-open state opens the breaker again
+	if ep.consecutiveFailures >= threshold {
+		ep.openUntil = now.Add(cooldown)
+		ep.metrics.BreakerOpenings++
+		log.Printf("contract: %s failed %d times in a row, not using it for %s\n", ep.baseUrl, ep.consecutiveFailures, cooldown)
+	}
+}
+
+func (ep *endpoint) onRateLimited(now time.Time, err error, until time.Time) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	ep.metrics.RateLimited++
+	ep.metrics.LastErrorAt = &now
+	ep.metrics.LastError = err.Error()
+
+	if until.After(ep.retryAfter) {
+		ep.retryAfter = until
+	}
+}
+
+// returns the endpoint to use, starting the search at index start. An endpoint that is available now is preferred,
+// otherwise the one that becomes available first
+func (c *Client) pick(now time.Time, start int) (int, time.Time) {
+	best := -1
+	var bestAt time.Time
+
+	for i := range c.endpoints {
+		idx := (start + i) % len(c.endpoints)
+
+		at := c.endpoints[idx].availableAt(now, c.conf.BaseBackoff)
+		if !at.After(now) {
+			return idx, now
+		}
+
+		if best == -1 || at.Before(bestAt) {
+			best = idx
+			bestAt = at
+		}
+	}
+
+	return best, bestAt
+}
+
+// returns the backoff before the retry with the number, with equal jitter
+func (c *Client) backoff(retry int) time.Duration {
+	d := c.conf.BaseBackoff
+	for i := 1; i < retry && d < c.conf.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.conf.MaxBackoff)
+
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// parses the Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+
+	return time.Time{}, false
+}
+
+// requests a JSON value at the path of the Aleo node API and unmarshals it into result.
+// Returns ErrNotFound if the value doesn't exist, other errors wrap ErrNodeApi
+func (c *Client) getJson(ctx context.Context, path string, result interface{}) error {
+	c.calls.Add(1)
+
+	err := c.retry(ctx, path, result)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.failedCalls.Add(1)
+	}
+
+	return err
+}
+
+func (c *Client) retry(ctx context.Context, path string, result interface{}) error {
+	var lastErr error
+	start := 0
+	notFoundRetried := false
+	// set when the picked endpoint became unavailable during the wait, e.g. another call got it for a trial request.
+	// The attempt is made again without a backoff
+	endpointTaken := false
+
+	for attempt := 0; attempt < c.conf.MaxAttempts; {
+		now := time.Now()
+
+		idx, availableAt := c.pick(now, start)
+		wait := availableAt.Sub(now)
+		if attempt > 0 && !endpointTaken {
+			c.retries.Add(1)
+			wait = max(wait, c.backoff(attempt))
+		}
+
+		if wait > c.conf.MaxRetryWait {
+			if lastErr == nil {
+				return fmt.Errorf("%w: %w", ErrNodeApi, ErrEndpointsUnavailable)
+			}
+			return fmt.Errorf("%w: %w", lastErr, ErrEndpointsUnavailable)
+		}
+
+		if attempt > 0 && !endpointTaken {
+			log.Printf("contract: requesting %s failed: %s, retrying in %s\n", path, lastErr, wait.Round(time.Millisecond))
+		}
+
+		if err := c.sleep(ctx, wait); err != nil {
+			return fmt.Errorf("%w: %w", ErrNodeApi, err)
+		}
+
+		ep := c.endpoints[idx]
+
+		probe, ok := ep.acquire(time.Now(), c.conf.BreakerThreshold, c.conf.RequestTimeout)
+		if !ok {
+			endpointTaken = true
+			continue
+		}
+
+		endpointTaken = false
+		attempt++
+
+		retryable, err := c.request(ctx, ep, path, result)
+		if probe {
+			ep.endProbe()
+		}
+		if err == nil {
+			return nil
+		}
+
+		if !retryable || ctx.Err() != nil {
+			return err
+		}
+
+		// nodes can lag behind, a missing value is requested once more from another endpoint
+		if errors.Is(err, ErrNotFound) {
+			if notFoundRetried {
+				return err
+			}
+			notFoundRetried = true
+		}
+
+		lastErr = err
+		start = idx + 1
+	}
+
+	return lastErr
+}
+
+// makes one request to the endpoint and records the outcome. Returns whether a failed request can be retried
+func (c *Client) request(ctx context.Context, ep *endpoint, path string, result interface{}) (bool, error) {
+	requestUrl := ep.baseUrl + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrNodeApi, err)
+	}
+
+	ep.onRequest()
+
+	resp, err := c.httpClient.Do(req)
+	now := time.Now()
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrNodeApi, err)
+		// the caller gave up, the endpoint is not at fault
+		if ctx.Err() != nil {
+			return false, err
+		}
+
+		ep.onFailure(now, err, c.conf.BreakerThreshold, c.conf.BreakerCooldown)
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// reads one byte past the limit to tell a response of exactly the limit from a larger one
+		body, err := io.ReadAll(io.LimitReader(resp.Body, c.conf.MaxResponseSize+1))
+		if err == nil && int64(len(body)) > c.conf.MaxResponseSize {
+			err = fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, c.conf.MaxResponseSize)
+		}
+		if err == nil {
+			err = json.Unmarshal(body, result)
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: %s: %w", ErrNodeApi, requestUrl, err)
+			ep.onFailure(now, err, c.conf.BreakerThreshold, c.conf.BreakerCooldown)
+			return true, err
+		}
+
+		ep.onSuccess(now)
+		return false, nil
+
+	case resp.StatusCode == http.StatusNotFound:
+		ep.onSuccess(now)
+		return true, ErrNotFound
+
+	case resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusServiceUnavailable && hasRetryAfter):
+		err = fmt.Errorf("%w: %s responded with %d, try again later", ErrNodeApi, requestUrl, resp.StatusCode)
+		ep.onRateLimited(now, err, retryAfter)
+		return true, err
+
+	case resp.StatusCode >= http.StatusInternalServerError:
+		err = fmt.Errorf("%w: %s responded with %d", ErrNodeApi, requestUrl, resp.StatusCode)
+		ep.onFailure(now, err, c.conf.BreakerThreshold, c.conf.BreakerCooldown)
+		return true, err
+
+	default:
+		// the endpoint works, but rejects the request
+		ep.onSuccess(now)
+		return false, fmt.Errorf("%w: %s did not get an OK response, got %d", ErrNodeApi, requestUrl, resp.StatusCode)
+	}
+}
@@ -0,0 +1,500 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubEndpoint responds with the statuses in order, then with the last one. A 200 response has the height 42
+type stubEndpoint struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	requests   int
+}
+
+func newStubEndpoint(t *testing.T, retryAfter string, statuses ...int) (*stubEndpoint, string) {
+	stub := &stubEndpoint{statuses: statuses, retryAfter: retryAfter}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+
+		status := stub.statuses[min(stub.requests, len(stub.statuses)-1)]
+		stub.requests++
+
+		if stub.retryAfter != "" {
+			w.Header().Set("Retry-After", stub.retryAfter)
+		}
+
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte("42"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return stub, server.URL
+}
+
+func (s *stubEndpoint) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// creates a client that records the waits instead of sleeping
+func newRecordingClient(t *testing.T, conf ClientConfig) (*Client, *[]time.Duration) {
+	t.Helper()
+
+	client, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waits := new([]time.Duration)
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+
+	return client, waits
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryAfter    string
+		statuses      []int
+		wantErr       error
+		wantRequests  int
+		wantRetries   uint64
+		wantMinWait   time.Duration
+		wantFailures  uint64
+		wantRateLimit uint64
+	}{
+		{
+			name:         "success",
+			statuses:     []int{http.StatusOK},
+			wantRequests: 1,
+		},
+		{
+			name:         "server errors then success",
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantRequests: 3,
+			wantRetries:  2,
+			wantFailures: 2,
+		},
+		{
+			name:          "rate limited with Retry-After",
+			retryAfter:    "7",
+			statuses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			wantRequests:  2,
+			wantRetries:   1,
+			wantMinWait:   6 * time.Second,
+			wantRateLimit: 1,
+		},
+		{
+			name:          "rate limited without Retry-After",
+			statuses:      []int{http.StatusTooManyRequests},
+			wantErr:       ErrNodeApi,
+			wantRequests:  DefaultMaxAttempts,
+			wantRetries:   DefaultMaxAttempts - 1,
+			wantRateLimit: DefaultMaxAttempts,
+		},
+		{
+			name:         "Retry-After longer than the maximum wait",
+			retryAfter:   "3600",
+			statuses:     []int{http.StatusServiceUnavailable},
+			wantErr:      ErrEndpointsUnavailable,
+			wantRequests: 1,
+			// the retry is counted, but given up before the request
+			wantRetries:   1,
+			wantRateLimit: 1,
+		},
+		{
+			name:         "not found is retried once",
+			statuses:     []int{http.StatusNotFound},
+			wantErr:      ErrNotFound,
+			wantRequests: 2,
+			wantRetries:  1,
+		},
+		{
+			name:         "client errors are not retried",
+			statuses:     []int{http.StatusBadRequest},
+			wantErr:      ErrNodeApi,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, url := newStubEndpoint(t, tt.retryAfter, tt.statuses...)
+			client, waits := newRecordingClient(t, ClientConfig{Endpoints: []string{url}})
+
+			height, err := client.GetLatestHeight(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetLatestHeight() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && height != 42 {
+				t.Errorf("GetLatestHeight() = %d, want 42", height)
+			}
+
+			if got := stub.count(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+
+			metrics := client.Metrics()
+			if metrics.Calls != 1 || metrics.Retries != tt.wantRetries {
+				t.Errorf("calls = %d, retries = %d, want 1 and %d", metrics.Calls, metrics.Retries, tt.wantRetries)
+			}
+
+			endpoint := metrics.Endpoints[0]
+			if endpoint.Failures != tt.wantFailures || endpoint.RateLimited != tt.wantRateLimit {
+				t.Errorf("unexpected endpoint metrics %+v", endpoint)
+			}
+
+			for idx, wait := range *waits {
+				if idx > 0 && (wait <= 0 || wait > DefaultMaxBackoff && tt.wantMinWait == 0) {
+					t.Errorf("wait %d = %s is out of bounds", idx, wait)
+				}
+			}
+			if tt.wantMinWait != 0 && (len(*waits) < 2 || (*waits)[1] < tt.wantMinWait) {
+				t.Errorf("expected a wait of at least %s, got %v", tt.wantMinWait, *waits)
+			}
+		})
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	primary, primaryUrl := newStubEndpoint(t, "", http.StatusInternalServerError)
+	secondary, secondaryUrl := newStubEndpoint(t, "", http.StatusOK)
+
+	client, _ := newRecordingClient(t, ClientConfig{
+		Endpoints:        []string{primaryUrl, secondaryUrl},
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+	})
+
+	// every call fails on the primary endpoint and moves on to the secondary one
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetLatestHeight(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if primary.count() != 2 || secondary.count() != 2 {
+		t.Fatalf("got %d primary and %d secondary requests, want 2 and 2", primary.count(), secondary.count())
+	}
+
+	metrics := client.Metrics()
+	if metrics.Endpoints[0].Breaker != BREAKER_OPEN || metrics.Endpoints[0].BreakerOpenings != 1 {
+		t.Errorf("expected the primary breaker to be open, got %+v", metrics.Endpoints[0])
+	}
+	if metrics.Endpoints[1].Breaker != BREAKER_CLOSED || metrics.Endpoints[1].Successes != 2 {
+		t.Errorf("unexpected secondary metrics %+v", metrics.Endpoints[1])
+	}
+
+	// the open breaker skips the primary endpoint
+	if _, err := client.GetLatestHeight(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if primary.count() != 2 || secondary.count() != 3 {
+		t.Errorf("got %d primary and %d secondary requests, want 2 and 3", primary.count(), secondary.count())
+	}
+
+	if client.Metrics().Retries != 2 {
+		t.Errorf("expected 2 retries, got %d", client.Metrics().Retries)
+	}
+}
+
+func TestClient_BreakerHalfOpen(t *testing.T) {
+	stub, url := newStubEndpoint(t, "", http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+
+	client, _ := newRecordingClient(t, ClientConfig{
+		Endpoints:        []string{url},
+		MaxAttempts:      1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetLatestHeight(context.Background()); !errors.Is(err, ErrNodeApi) {
+			t.Fatalf("expected %v, got %v", ErrNodeApi, err)
+		}
+	}
+
+	// every endpoint is unavailable for longer than the maximum wait
+	if _, err := client.GetLatestHeight(context.Background()); !errors.Is(err, ErrEndpointsUnavailable) {
+		t.Fatalf("expected %v, got %v", ErrEndpointsUnavailable, err)
+	}
+	if stub.count() != 2 {
+		t.Fatalf("got %d requests to an open endpoint", stub.count())
+	}
+
+	// end the cooldown
+	client.endpoints[0].openUntil = time.Now().Add(-time.Second)
+	if state := client.Metrics().Endpoints[0].Breaker; state != BREAKER_HALF_OPEN {
+		t.Fatalf("breaker = %s, want %s", state, BREAKER_HALF_OPEN)
+	}
+
+	if _, err := client.GetLatestHeight(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := client.Metrics()
+	if metrics.Endpoints[0].Breaker != BREAKER_CLOSED || metrics.FailedCalls != 3 {
+		t.Errorf("unexpected metrics after a successful trial %+v", metrics)
+	}
+}
+
+func TestClient_BreakerHalfOpenConcurrent(t *testing.T) {
+	const callers = 10
+
+	tests := []struct {
+		name        string
+		trialStatus int
+		// requests after the trial request
+		wantRequests int
+		wantBreaker  string
+	}{
+		{
+			name:         "successful trial",
+			trialStatus:  http.StatusOK,
+			wantRequests: callers - 1,
+			wantBreaker:  BREAKER_CLOSED,
+		},
+		{
+			name:         "failed trial",
+			trialStatus:  http.StatusInternalServerError,
+			wantRequests: 0,
+			wantBreaker:  BREAKER_OPEN,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			trialStarted := make(chan struct{})
+			releaseTrial := make(chan struct{})
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				requests++
+				current := requests
+				mu.Unlock()
+
+				switch current {
+				case 1:
+					w.WriteHeader(http.StatusInternalServerError)
+				case 2:
+					close(trialStarted)
+					<-releaseTrial
+					w.WriteHeader(tt.trialStatus)
+					w.Write([]byte("42"))
+				default:
+					w.Write([]byte("42"))
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{
+				Endpoints:        []string{server.URL},
+				MaxAttempts:      1,
+				BaseBackoff:      time.Millisecond,
+				MaxBackoff:       time.Millisecond,
+				BreakerThreshold: 1,
+				BreakerCooldown:  time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.GetLatestHeight(context.Background()); !errors.Is(err, ErrNodeApi) {
+				t.Fatalf("expected %v, got %v", ErrNodeApi, err)
+			}
+
+			// end the cooldown
+			client.endpoints[0].mu.Lock()
+			client.endpoints[0].openUntil = time.Now().Add(-time.Second)
+			client.endpoints[0].mu.Unlock()
+
+			errs := make(chan error, callers)
+			for i := 0; i < callers; i++ {
+				go func() {
+					_, err := client.GetLatestHeight(context.Background())
+					errs <- err
+				}()
+			}
+
+			<-trialStarted
+			// give the other callers time to send requests if the breaker let them
+			time.Sleep(50 * time.Millisecond)
+
+			mu.Lock()
+			inTrial := requests
+			mu.Unlock()
+			if inTrial != 2 {
+				t.Errorf("got %d requests during the trial, want 1", inTrial-1)
+			}
+
+			close(releaseTrial)
+
+			failed := 0
+			for i := 0; i < callers; i++ {
+				if err := <-errs; err != nil {
+					failed++
+				}
+			}
+
+			if tt.trialStatus == http.StatusOK && failed != 0 {
+				t.Errorf("%d calls failed after a successful trial", failed)
+			}
+			if tt.trialStatus != http.StatusOK && failed != callers {
+				t.Errorf("%d calls succeeded after a failed trial", callers-failed)
+			}
+
+			mu.Lock()
+			afterTrial := requests - 2
+			mu.Unlock()
+			if afterTrial != tt.wantRequests {
+				t.Errorf("got %d requests after the trial, want %d", afterTrial, tt.wantRequests)
+			}
+
+			if state := client.Metrics().Endpoints[0].Breaker; state != tt.wantBreaker {
+				t.Errorf("breaker = %s, want %s", state, tt.wantBreaker)
+			}
+		})
+	}
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	_, url := newStubEndpoint(t, "", http.StatusInternalServerError)
+
+	client, err := NewClient(ClientConfig{Endpoints: []string{url}, BaseBackoff: time.Hour, MaxBackoff: time.Hour, MaxRetryWait: 2 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		_, err := client.GetLatestHeight(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrNodeApi) {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the call didn't stop after the context was canceled")
+	}
+}
+
+func TestClient_MaxResponseSize(t *testing.T) {
+	// the stub responds with the 2 bytes "42"
+	tests := []struct {
+		name            string
+		maxResponseSize int64
+		wantErr         error
+		wantFailures    uint64
+	}{
+		{
+			name:            "response at the limit",
+			maxResponseSize: 2,
+		},
+		{
+			name:            "response over the limit",
+			maxResponseSize: 1,
+			wantErr:         ErrResponseTooLarge,
+			wantFailures:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := newStubEndpoint(t, "", http.StatusOK)
+
+			client, _ := newRecordingClient(t, ClientConfig{
+				Endpoints:       []string{url},
+				MaxAttempts:     1,
+				MaxResponseSize: tt.maxResponseSize,
+			})
+
+			height, err := client.GetLatestHeight(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && height != 42 {
+				t.Errorf("height = %d, want 42", height)
+			}
+			if tt.wantErr != nil && !errors.Is(err, ErrNodeApi) {
+				t.Errorf("expected %v, got %v", ErrNodeApi, err)
+			}
+
+			if failures := client.Metrics().Endpoints[0].Failures; failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []string
+		wantErr   bool
+	}{
+		{name: "no endpoints", wantErr: true},
+		{name: "not a URL", endpoints: []string{"api.explorer.provable.com"}, wantErr: true},
+		{name: "valid", endpoints: []string{"https://api.explorer.provable.com/v1/testnet/", "http://localhost:3030/testnet"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(ClientConfig{Endpoints: tt.endpoints})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && client.Endpoints()[0] != "https://api.explorer.provable.com/v1/testnet" {
+				t.Errorf("unexpected endpoints %v", client.Endpoints())
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 9, 9, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Time
+		wantOk bool
+	}{
+		{value: "", wantOk: false},
+		{value: "30", want: now.Add(30 * time.Second), wantOk: true},
+		{value: "Mon, 09 Sep 2024 08:01:00 GMT", want: now.Add(time.Minute), wantOk: true},
+		{value: "soon", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOk || !got.Equal(tt.want) {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
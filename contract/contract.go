@@ -1,82 +1,55 @@
 package contract
 
 import (
+	"context"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
-	"io"
-	"log"
-	"math/big"
-	"net/http"
+	"fmt"
 	"net/url"
-	"strings"
-	"time"
-)
-
-func bigIntStrToBytes(strBigInt string) []byte {
-	num := new(big.Int)
-
-	num, ok := num.SetString(strBigInt, 10)
-	if !ok {
-		return nil
-	}
-
-	bytes := make([]byte, 16)
 
-	// Extract bytes in little-endian order
-	for i := 0; i < 16; i++ {
-		b := byte(num.Uint64() & 0xff)
-		bytes[i] = b
-
-		num.Rsh(num, 8)
-	}
+	"github.com/zkportal/oracle-verification-backend/leo"
+)
 
-	return bytes
-}
+var (
+	ErrNodeApi     = errors.New("contract: Aleo node API request failed")
+	ErrNotFound    = errors.New("contract: not found")
+	ErrValueNotSet = errors.New("contract: value is not set")
+)
 
-func requestProgramString(c *http.Client, url string, retry bool) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
-	}
+// requests a program value at the path of the Aleo node API, a mapping value is a Leo value as a string
+func (c *Client) requestProgramString(ctx context.Context, path string) (string, error) {
+	var result string
 
-	resp, err := c.Do(req)
+	err := c.getJson(ctx, path, &result)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if retry && (resp.StatusCode == http.StatusInternalServerError || resp.StatusCode == http.StatusNotFound) {
-		log.Printf("contract: requesting %s returned %d, trying again\n", url, resp.StatusCode)
-		time.Sleep(3 * time.Second)
-		return requestProgramString(c, url, false)
-	}
-
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return "", errors.New("contract: Aleo node API responded with 429 Too Many Requests, try again later")
+	if result == "null" {
+		return "", ErrValueNotSet
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("contract: did not get an OK response")
-	}
+	return result, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// concatenates the little-endian bytes of the u128 struct members with the names, in order
+func concatU128Members(value leo.Value, names ...string) ([]byte, error) {
+	s, ok := value.(*leo.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected struct, got %s", leo.ErrUnexpectedType, leo.TypeOf(value))
 	}
 
-	var result string
-
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return "", err
-	}
+	buf := make([]byte, 0, len(names)*16)
+	for _, name := range names {
+		number, err := s.Integer(name, leo.TYPE_U128)
+		if err != nil {
+			return nil, err
+		}
 
-	if result == "null" {
-		return "", errors.New("contract: value is not set")
+		buf = append(buf, number.LittleEndian()...)
 	}
 
-	return result, nil
+	return buf, nil
 }
 
 func parseSgxUniqueIdStruct(uniqueIdStructString string) (string, error) {
@@ -86,28 +59,14 @@ func parseSgxUniqueIdStruct(uniqueIdStructString string) (string, error) {
 	//   chunk_2: u128
 	// }
 
-	chunks := strings.Split(uniqueIdStructString, "\n")
-
-	uniqueId := make([]byte, 0, 32)
-
-	for _, chunk := range chunks {
-		if !strings.Contains(chunk, "chunk_") {
-			continue
-		}
-
-		valBegin := strings.Index(chunk, ": ")
-		valEnd := strings.Index(chunk, "u128")
-
-		if valBegin == -1 || valEnd == -1 {
-			return "", errors.New("unexpected type of unique ID chunks in sgx_unique_id mapping")
-		}
-
-		uniqueIdPart := chunk[valBegin+2 : valEnd]
-		uniqueId = append(uniqueId, bigIntStrToBytes(uniqueIdPart)...)
+	value, err := leo.Parse(uniqueIdStructString)
+	if err != nil {
+		return "", fmt.Errorf("malformed unique ID in sgx_unique_id mapping: %w", err)
 	}
 
-	if len(uniqueId) != 32 {
-		return "", errors.New("malformed unique id in the contract")
+	uniqueId, err := concatU128Members(value, "chunk_1", "chunk_2")
+	if err != nil {
+		return "", fmt.Errorf("malformed unique ID in sgx_unique_id mapping: %w", err)
 	}
 
 	return hex.EncodeToString(uniqueId), nil
@@ -127,35 +86,19 @@ func parseNitroPcrValues(nitroPcrStructString string) ([]string, error) {
 	//   pcr_2_chunk_3: u128
 	// }
 
-	chunks := strings.Split(nitroPcrStructString, "\n")
-
-	pcrValues := make([][]byte, 0, 9)
-	for _, chunk := range chunks {
-		if !strings.Contains(chunk, "pcr_") {
-			continue
-		}
-
-		valBegin := strings.Index(chunk, ": ")
-		valEnd := strings.Index(chunk, "u128")
-
-		if valBegin == -1 || valEnd == -1 {
-			return nil, errors.New("unexpected type of PCR value chunks in nitro_pcr_values mapping")
-		}
-
-		pcrValuePart := chunk[valBegin+2 : valEnd]
-		pcrValues = append(pcrValues, bigIntStrToBytes(pcrValuePart))
-	}
-
-	if len(pcrValues) != 9 {
-		return nil, errors.New("unexpected type of PCR values struct in nitro_pcr_values mapping")
+	value, err := leo.Parse(nitroPcrStructString)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PCR values in nitro_pcr_values mapping: %w", err)
 	}
 
 	pcrs := make([]string, 3)
 	for pcrIdx := 0; pcrIdx < 3; pcrIdx++ {
-		pcr := make([]byte, 0, 48)
-		pcr = append(pcr, pcrValues[pcrIdx*3]...)
-		pcr = append(pcr, pcrValues[pcrIdx*3+1]...)
-		pcr = append(pcr, pcrValues[pcrIdx*3+2]...)
+		prefix := fmt.Sprintf("pcr_%d_chunk_", pcrIdx)
+
+		pcr, err := concatU128Members(value, prefix+"1", prefix+"2", prefix+"3")
+		if err != nil {
+			return nil, fmt.Errorf("malformed PCR values in nitro_pcr_values mapping: %w", err)
+		}
 
 		pcrs[pcrIdx] = hex.EncodeToString(pcr)
 	}
@@ -165,16 +108,8 @@ func parseNitroPcrValues(nitroPcrStructString string) ([]string, error) {
 
 // Retrieves the SGX unique ID from the contract that it uses to verify reports.
 // The contract must have a mapping called sgx_unique_id, where the value us stored as a struct under the "0u8" key.
-func GetSgxUniqueIDAssert(apiBaseUrl, contractName string) (string, error) {
-	apiBaseUrl = strings.TrimSuffix(apiBaseUrl, "/")
-
-	requestUrl := apiBaseUrl + "/program/" + url.PathEscape(contractName) + "/mapping/sgx_unique_id/0u8"
-
-	client := &http.Client{
-		Timeout: time.Second * 30,
-	}
-
-	uniqueIdStructString, err := requestProgramString(client, requestUrl, true)
+func (c *Client) GetSgxUniqueIDAssert(ctx context.Context, contractName string) (string, error) {
+	uniqueIdStructString, err := c.requestProgramString(ctx, "/program/"+url.PathEscape(contractName)+"/mapping/sgx_unique_id/0u8")
 	if err != nil {
 		return "", err
 	}
@@ -184,16 +119,8 @@ func GetSgxUniqueIDAssert(apiBaseUrl, contractName string) (string, error) {
 
 // Retrieves the Nitro PCR values from the contract that it uses to verify reports.
 // The contract must have a mapping called nitro_pcr_values, where the value us stored as a struct under the "0u8" key.
-func GetNitroPcrValuesAssert(apiBaseUrl, contractName string) ([]string, error) {
-	apiBaseUrl = strings.TrimSuffix(apiBaseUrl, "/")
-
-	requestUrl := apiBaseUrl + "/program/" + url.PathEscape(contractName) + "/mapping/nitro_pcr_values/0u8"
-
-	client := &http.Client{
-		Timeout: time.Second * 30,
-	}
-
-	pcrsStructString, err := requestProgramString(client, requestUrl, true)
+func (c *Client) GetNitroPcrValuesAssert(ctx context.Context, contractName string) ([]string, error) {
+	pcrsStructString, err := c.requestProgramString(ctx, "/program/"+url.PathEscape(contractName)+"/mapping/nitro_pcr_values/0u8")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,235 @@
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/leo"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+var (
+	ErrTransactionNotFound    = errors.New("contract: transaction not found")
+	ErrNoOracleTransition     = errors.New("contract: transaction has no oracle update transition")
+	ErrInvalidTransitionInput = errors.New("contract: invalid oracle update transition input")
+)
+
+// positions of the oracle update transition inputs, e.g.
+// transition set_data_sgx(public report_data: ReportData, public report: Report, public sig: signature, public pub_key: address)
+const (
+	reportDataInputIdx = 0
+	reportInputIdx     = 1
+)
+
+// Default names of the oracle update transitions
+const (
+	DefaultSgxUpdateFunction   = "set_data_sgx"
+	DefaultNitroUpdateFunction = "set_data_nitro"
+)
+
+// UpdateFunctions are the names of the oracle contract's update transitions. Transitions with other names
+// are not oracle updates. Empty names are replaced with the defaults
+type UpdateFunctions struct {
+	Sgx   string `json:"sgx"`
+	Nitro string `json:"nitro"`
+}
+
+// returns the TEE type of the report that an oracle update transition takes, or an empty string
+// if the function is not an oracle update
+func (f UpdateFunctions) reportType(function string) string {
+	sgxFunction, nitroFunction := f.Sgx, f.Nitro
+	if sgxFunction == "" {
+		sgxFunction = DefaultSgxUpdateFunction
+	}
+	if nitroFunction == "" {
+		nitroFunction = DefaultNitroUpdateFunction
+	}
+
+	switch function {
+	case sgxFunction:
+		return attestation.TEE_TYPE_SGX
+	case nitroFunction:
+		return attestation.TEE_TYPE_NITRO
+	default:
+		return ""
+	}
+}
+
+type TransitionInput struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+type Transition struct {
+	ID       string            `json:"id"`
+	Program  string            `json:"program"`
+	Function string            `json:"function"`
+	Inputs   []TransitionInput `json:"inputs"`
+}
+
+// Transaction as returned by the Aleo node API, only the fields that are needed to find oracle updates
+type Transaction struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Execution *struct {
+		Transitions []Transition `json:"transitions"`
+	} `json:"execution"`
+}
+
+// Inputs of an oracle update transition
+type OracleTransition struct {
+	TransactionID string `json:"transactionId"`
+	TransitionID  string `json:"transitionId"`
+	Program       string `json:"program"`
+	Function      string `json:"function"`
+	// one of the attestation.TEE_TYPE_ values
+	ReportType string `json:"reportType"`
+	// ReportData Leo struct with the proof data
+	ReportData string `json:"reportData"`
+	// Leo struct with the report
+	Report string `json:"report"`
+}
+
+// VerifiedTransaction is an oracle update transition that passed verification
+type VerifiedTransaction struct {
+	Transition *OracleTransition
+	*attestation.VerifiedOracleUpdate
+}
+
+// GetTransaction retrieves a transaction from the Aleo node API
+func (c *Client) GetTransaction(ctx context.Context, transactionId string) (*Transaction, error) {
+	tx := new(Transaction)
+	err := c.getJson(ctx, "/transaction/"+url.PathEscape(transactionId), tx)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTransactionNotFound, transactionId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// FindOracleTransitions finds all transitions in the transaction that update the oracle contract with an SGX or Nitro report,
+// in the order of execution. Fails if any of them has unexpected inputs
+func FindOracleTransitions(tx *Transaction, contractName string, functions UpdateFunctions) ([]*OracleTransition, error) {
+	if tx.Execution == nil {
+		return nil, ErrNoOracleTransition
+	}
+
+	var result []*OracleTransition
+
+	for _, transition := range tx.Execution.Transitions {
+		if transition.Program != contractName {
+			continue
+		}
+
+		reportType := functions.reportType(transition.Function)
+		if reportType == "" {
+			continue
+		}
+
+		if len(transition.Inputs) <= reportInputIdx {
+			return nil, fmt.Errorf("%w: %s has %d inputs", ErrInvalidTransitionInput, transition.Function, len(transition.Inputs))
+		}
+
+		reportData := transition.Inputs[reportDataInputIdx]
+		report := transition.Inputs[reportInputIdx]
+		if reportData.Type != "public" || report.Type != "public" {
+			return nil, fmt.Errorf("%w: %s report inputs are not public", ErrInvalidTransitionInput, transition.Function)
+		}
+
+		result = append(result, &OracleTransition{
+			TransactionID: tx.ID,
+			TransitionID:  transition.ID,
+			Program:       transition.Program,
+			Function:      transition.Function,
+			ReportType:    reportType,
+			ReportData:    reportData.Value,
+			Report:        report.Value,
+		})
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNoOracleTransition
+	}
+
+	return result, nil
+}
+
+// GetOracleTransitions retrieves a transaction from the Aleo node API and returns its oracle update transitions
+func (c *Client) GetOracleTransitions(ctx context.Context, contractName string, functions UpdateFunctions, transactionId string) ([]*OracleTransition, error) {
+	tx, err := c.GetTransaction(ctx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindOracleTransitions(tx, contractName, functions)
+}
+
+// RecoverReport returns the report bytes from the transition's report input without the padding of the Leo struct
+func (t *OracleTransition) RecoverReport() ([]byte, error) {
+	report, err := leo.ParseMessage(t.Report)
+	if err != nil {
+		return nil, fmt.Errorf("%w: report: %w", ErrInvalidTransitionInput, err)
+	}
+
+	return attestation.TrimReportPadding(t.ReportType, report), nil
+}
+
+// VerifyOracleTransition verifies the report in an oracle update transition and decodes the proof data that the report commits to
+func VerifyOracleTransition(aleoSession aleo_wrapper.Session, transition *OracleTransition, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*VerifiedTransaction, error) {
+	proofData, err := leo.ParseMessage(transition.ReportData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: report data: %w", ErrInvalidTransitionInput, err)
+	}
+
+	report, err := transition.RecoverReport()
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := attestation.VerifyOracleUpdate(aleoSession, transition.ReportType, report, proofData, measurements, sgxPolicy, verificationTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifiedTransaction{
+		Transition:           transition,
+		VerifiedOracleUpdate: update,
+	}, nil
+}
+
+// VerifyTransaction retrieves an oracle update transaction from the Aleo node API, then verifies the report
+// in every update transition as of the time of the transaction's block and decodes the proof data that the report commits to.
+// Fails if any of the updates fails.
+func (c *Client) VerifyTransaction(ctx context.Context, aleoSession aleo_wrapper.Session, contractName string, functions UpdateFunctions, transactionId string, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy) ([]*VerifiedTransaction, error) {
+	transitions, err := c.GetOracleTransitions(ctx, contractName, functions, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationTime, err := c.GetTransactionTime(ctx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*VerifiedTransaction, 0, len(transitions))
+	for _, transition := range transitions {
+		verified, err := VerifyOracleTransition(aleoSession, transition, measurements, sgxPolicy, verificationTime)
+		if err != nil {
+			return nil, fmt.Errorf("transition %s: %w", transition.TransitionID, err)
+		}
+
+		result = append(result, verified)
+	}
+
+	return result, nil
+}
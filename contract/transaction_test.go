@@ -0,0 +1,420 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+	"github.com/zkportal/oracle-verification-backend/leo"
+
+	encoding "github.com/zkportal/aleo-oracle-encoding"
+)
+
+const testContractName = "official_oracle.aleo"
+
+func oracleTransaction(id, program, function, inputType string, inputs ...string) *Transaction {
+	tx := &Transaction{
+		Type: "execute",
+		ID:   id,
+		Execution: &struct {
+			Transitions []Transition `json:"transitions"`
+		}{},
+	}
+
+	transition := Transition{
+		ID:       "au1" + id,
+		Program:  program,
+		Function: function,
+	}
+	for idx, input := range inputs {
+		transition.Inputs = append(transition.Inputs, TransitionInput{Type: inputType, ID: string(rune('a' + idx)), Value: input})
+	}
+
+	tx.Execution.Transitions = append(tx.Execution.Transitions, Transition{ID: "au1fee", Program: "credits.aleo", Function: "transfer_public"}, transition)
+
+	return tx
+}
+
+// adds another update transition to the transaction, with the ID au2 followed by the transaction ID
+func withSecondUpdate(tx *Transaction, function, inputType string, inputs ...string) *Transaction {
+	second := oracleTransaction(tx.ID, testContractName, function, inputType, inputs...).Execution.Transitions[1]
+	second.ID = "au2" + tx.ID
+	tx.Execution.Transitions = append(tx.Execution.Transitions, second)
+
+	return tx
+}
+
+// creates a client for the endpoints that doesn't wait between retries
+func newTestClient(t *testing.T, endpoints ...string) *Client {
+	t.Helper()
+
+	client, err := NewClient(ClientConfig{Endpoints: endpoints})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		return ctx.Err()
+	}
+
+	return client
+}
+
+// creation time of the blocks served by the node stub
+const testBlockTimestamp = 1701851064
+
+// starts a stub of the Aleo node API that serves the transactions and returns a client for it.
+// Every transaction is in its own block with the hash "ab1" followed by the transaction ID
+func newNodeStub(t *testing.T, transactions ...*Transaction) *Client {
+	find := func(id string) *Transaction {
+		for _, tx := range transactions {
+			if tx.ID == id {
+				return tx
+			}
+		}
+		return nil
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, "/testnet")
+
+		if id, ok := strings.CutPrefix(path, "/find/blockHash/"); ok {
+			if find(id) == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode("ab1" + id)
+			return
+		}
+
+		if id, ok := strings.CutPrefix(path, "/block/ab1"); ok {
+			if find(id) == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			block := &Block{BlockHash: "ab1" + id}
+			block.Header.Metadata.Timestamp = testBlockTimestamp
+			json.NewEncoder(w).Encode(block)
+			return
+		}
+
+		id := strings.TrimPrefix(path, "/transaction/")
+
+		switch id {
+		case "at1error":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "at1busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		if tx := find(id); tx != nil {
+			json.NewEncoder(w).Encode(tx)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	return newTestClient(t, server.URL+"/testnet/")
+}
+
+func TestGetOracleTransitions(t *testing.T) {
+	client := newNodeStub(t,
+		oracleTransaction("at1sgx", testContractName, "set_data_sgx", "public", "data", "report", "sign1", "aleo1"),
+		oracleTransaction("at1nitro", testContractName, "set_data_nitro", "public", "data", "report", "sign1", "aleo1"),
+		oracleTransaction("at1other", "other.aleo", "set_data_sgx", "public", "data", "report"),
+		oracleTransaction("at1private", testContractName, "set_data_sgx", "private", "data", "report"),
+		oracleTransaction("at1short", testContractName, "set_data_sgx", "public", "data"),
+		oracleTransaction("at1similar", testContractName, "set_data_sgx_v2", "public", "data", "report"),
+		oracleTransaction("at1custom", testContractName, "update_nitro", "public", "data", "report"),
+		withSecondUpdate(oracleTransaction("at1both", testContractName, "set_data_sgx", "public", "data", "report"), "set_data_nitro", "public", "data", "report"),
+		withSecondUpdate(oracleTransaction("at1bothshort", testContractName, "set_data_sgx", "public", "data", "report"), "set_data_nitro", "public", "data"),
+		&Transaction{Type: "deploy", ID: "at1deploy"},
+	)
+
+	tests := []struct {
+		name            string
+		transactionId   string
+		functions       UpdateFunctions
+		wantReportTypes []string
+		wantErr         error
+	}{
+		{
+			name:            "sgx update",
+			transactionId:   "at1sgx",
+			wantReportTypes: []string{attestation.TEE_TYPE_SGX},
+		},
+		{
+			name:            "nitro update",
+			transactionId:   "at1nitro",
+			wantReportTypes: []string{attestation.TEE_TYPE_NITRO},
+		},
+		{
+			name:            "sgx and nitro updates",
+			transactionId:   "at1both",
+			wantReportTypes: []string{attestation.TEE_TYPE_SGX, attestation.TEE_TYPE_NITRO},
+		},
+		{
+			name:          "second update with missing inputs",
+			transactionId: "at1bothshort",
+			wantErr:       ErrInvalidTransitionInput,
+		},
+		{
+			name:          "function with a similar name",
+			transactionId: "at1similar",
+			wantErr:       ErrNoOracleTransition,
+		},
+		{
+			name:            "configured function",
+			transactionId:   "at1custom",
+			functions:       UpdateFunctions{Nitro: "update_nitro"},
+			wantReportTypes: []string{attestation.TEE_TYPE_NITRO},
+		},
+		{
+			name:          "default function when another one is configured",
+			transactionId: "at1nitro",
+			functions:     UpdateFunctions{Nitro: "update_nitro"},
+			wantErr:       ErrNoOracleTransition,
+		},
+		{
+			name:          "unknown transaction",
+			transactionId: "at1unknown",
+			wantErr:       ErrTransactionNotFound,
+		},
+		{
+			name:          "other program",
+			transactionId: "at1other",
+			wantErr:       ErrNoOracleTransition,
+		},
+		{
+			name:          "deployment",
+			transactionId: "at1deploy",
+			wantErr:       ErrNoOracleTransition,
+		},
+		{
+			name:          "private inputs",
+			transactionId: "at1private",
+			wantErr:       ErrInvalidTransitionInput,
+		},
+		{
+			name:          "missing inputs",
+			transactionId: "at1short",
+			wantErr:       ErrInvalidTransitionInput,
+		},
+		{
+			name:          "node error",
+			transactionId: "at1error",
+			wantErr:       ErrNodeApi,
+		},
+		{
+			name:          "rate limited",
+			transactionId: "at1busy",
+			wantErr:       ErrNodeApi,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.GetOracleTransitions(context.Background(), testContractName, tt.functions, tt.transactionId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetOracleTransitions() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(got) != len(tt.wantReportTypes) {
+				t.Fatalf("GetOracleTransitions() returned %d transitions, want %d", len(got), len(tt.wantReportTypes))
+			}
+
+			for idx, transition := range got {
+				want := &OracleTransition{
+					TransactionID: tt.transactionId,
+					TransitionID:  "au" + strconv.Itoa(idx+1) + tt.transactionId,
+					Program:       testContractName,
+					Function:      transition.Function,
+					ReportType:    tt.wantReportTypes[idx],
+					ReportData:    "data",
+					Report:        "report",
+				}
+				if *transition != *want {
+					t.Errorf("GetOracleTransitions()[%d] = %+v, want %+v", idx, transition, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTransactionTime(t *testing.T) {
+	client := newNodeStub(t, oracleTransaction("at1sgx", testContractName, "set_data_sgx", "public", "data", "report"))
+
+	got, err := client.GetTransactionTime(context.Background(), "at1sgx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(time.Unix(testBlockTimestamp, 0)) {
+		t.Errorf("GetTransactionTime() = %s, want %s", got, time.Unix(testBlockTimestamp, 0))
+	}
+
+	if _, err = client.GetTransactionTime(context.Background(), "at1unknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("GetTransactionTime() error = %v, want %v", err, ErrTransactionNotFound)
+	}
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	proofData, err := attestation.PrepareProofData(http.StatusOK, "42", 1701851063, &attestation.AttestationRequest{
+		Url:            "localhost:8080/resource",
+		RequestMethod:  http.MethodGet,
+		Selector:       "data.value",
+		ResponseFormat: "json",
+		EncodingOptions: encoding.EncodingOptions{
+			Value: encoding.ENCODING_OPTION_INT,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validData := testutil.LeoMessage(proofData)
+	report := testutil.LeoMessage([]byte("not a real report"))
+
+	client := newNodeStub(t,
+		oracleTransaction("at1valid", testContractName, "set_data_sgx", "public", validData, report),
+		oracleTransaction("at1baddata", testContractName, "set_data_sgx", "public", testutil.LeoMessage(proofData[:64]), report),
+		oracleTransaction("at1notformatted", testContractName, "set_data_sgx", "public", hex.EncodeToString(proofData), report),
+		oracleTransaction("at1badreport", testContractName, "set_data_sgx", "public", validData, "{ c0: { f0: 1u64 } }"),
+	)
+
+	tests := []struct {
+		name          string
+		transactionId string
+		check         func(err error) bool
+	}{
+		{
+			name:          "not found",
+			transactionId: "at1unknown",
+			check:         func(err error) bool { return errors.Is(err, ErrTransactionNotFound) },
+		},
+		{
+			name:          "proof data is not a formatted message",
+			transactionId: "at1notformatted",
+			check:         func(err error) bool { return errors.Is(err, ErrInvalidTransitionInput) },
+		},
+		{
+			name:          "report is not a formatted message",
+			transactionId: "at1badreport",
+			check: func(err error) bool {
+				return errors.Is(err, ErrInvalidTransitionInput) && errors.Is(err, leo.ErrUnexpectedType)
+			},
+		},
+		{
+			name:          "proof data cannot be decoded",
+			transactionId: "at1baddata",
+			check: func(err error) bool {
+				var decodeErr *attestation.DecodeError
+				return errors.As(err, &decodeErr)
+			},
+		},
+		{
+			name:          "report fails verification",
+			transactionId: "at1valid",
+			check: func(err error) bool {
+				var decodeErr *attestation.DecodeError
+				return err != nil && !errors.As(err, &decodeErr) && !errors.Is(err, ErrInvalidTransitionInput) && !errors.Is(err, ErrNodeApi)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.VerifyTransaction(context.Background(), new(testutil.FakeSession), testContractName, UpdateFunctions{}, tt.transactionId, nil, nil)
+			if got != nil || !tt.check(err) {
+				t.Errorf("VerifyTransaction() = %v, unexpected error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRecoverReport(t *testing.T) {
+	report := []byte("a report that is 32 bytes long!!")
+	formatted := testutil.LeoMessage(report)
+
+	multiLine := strings.NewReplacer("{ ", "{\n  ", ", ", ",\n  ", " }", "\n}").Replace(formatted)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr error
+	}{
+		{
+			name:  "single line",
+			input: formatted,
+			want:  report,
+		},
+		{
+			name:  "multiple lines",
+			input: multiLine,
+			want:  report,
+		},
+		{
+			name:  "public values",
+			input: strings.ReplaceAll(multiLine, "u128", "u128.public"),
+			want:  report,
+		},
+		{
+			name:  "private values",
+			input: strings.ReplaceAll(formatted, "u128", "u128.private"),
+			want:  report,
+		},
+		{
+			name:    "hex",
+			input:   hex.EncodeToString(report),
+			wantErr: leo.ErrSyntax,
+		},
+		{
+			name:    "wrong value type",
+			input:   strings.ReplaceAll(formatted, "u128", "field"),
+			wantErr: leo.ErrUnexpectedType,
+		},
+		{
+			name:    "unknown visibility",
+			input:   strings.ReplaceAll(formatted, "u128", "u128.secret"),
+			wantErr: leo.ErrSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition := &OracleTransition{ReportType: attestation.TEE_TYPE_SGX, Report: tt.input}
+
+			got, err := transition.RecoverReport()
+			if tt.wantErr != nil {
+				if !errors.Is(err, ErrInvalidTransitionInput) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RecoverReport() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RecoverReport() unexpected error = %v", err)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("RecoverReport() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
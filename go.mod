@@ -5,15 +5,17 @@ go 1.21.12
 require (
 	github.com/blocky/nitrite v0.0.2-0.20241022160405-a6f5b6da1e50
 	github.com/edgelesssys/ego v1.6.1
+	github.com/fxamacker/cbor/v2 v2.6.0
 	github.com/rs/cors v1.11.1
 	github.com/zkportal/aleo-oracle-encoding v1.0.0
 	github.com/zkportal/aleo-utils-go v1.1.3
+	go.etcd.io/bbolt v1.3.10
 )
 
 require (
-	github.com/fxamacker/cbor/v2 v2.6.0 // indirect
 	github.com/go-jose/go-jose/v4 v4.0.4 // indirect
 	github.com/tetratelabs/wazero v1.8.1 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
@@ -0,0 +1,158 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var recordsBucket = []byte("records")
+
+// BoltStore keeps records in a bbolt database file. Records are keyed by their ID, which increases
+// with the recording time, so time range queries stop at the first record outside of the range.
+type BoltStore struct {
+	db        *bolt.DB
+	retention Retention
+}
+
+// OpenBoltStore opens or creates the database file. Records that the retention doesn't keep are removed
+// when records are added
+func OpenBoltStore(path string, retention Retention) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(recordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db, retention: retention}, nil
+}
+
+func recordKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func (s *BoltStore) Add(records ...*Record) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+
+		// the time is taken inside the transaction, so that the recording times increase with the IDs
+		now := time.Now().UTC()
+		for _, r := range records {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			r.ID = id
+			r.RecordedAt = now
+
+			value, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+
+			if err = bucket.Put(recordKey(id), value); err != nil {
+				return err
+			}
+		}
+
+		return s.removeOld(bucket, now)
+	})
+	if err == bolt.ErrDatabaseNotOpen {
+		return ErrStoreClosed
+	}
+
+	return err
+}
+
+// removes the records that the retention doesn't keep. Records are only removed from the start,
+// so the IDs of the kept records are consecutive
+func (s *BoltStore) removeOld(bucket *bolt.Bucket, now time.Time) error {
+	cutoff := s.retention.cutoff(now)
+
+	for {
+		cursor := bucket.Cursor()
+
+		firstKey, value := cursor.First()
+		if firstKey == nil {
+			return nil
+		}
+		lastKey, _ := cursor.Last()
+
+		count := binary.BigEndian.Uint64(lastKey) - binary.BigEndian.Uint64(firstKey) + 1
+		remove := s.retention.MaxRecords > 0 && count > uint64(s.retention.MaxRecords)
+
+		if !remove && !cutoff.IsZero() {
+			r := Record{}
+			if err := json.Unmarshal(value, &r); err != nil {
+				return err
+			}
+			remove = r.RecordedAt.Before(cutoff)
+		}
+
+		if !remove {
+			return nil
+		}
+
+		if err := bucket.Delete(firstKey); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *BoltStore) Query(filter *Filter) ([]Record, error) {
+	limit := filter.limit()
+	result := make([]Record, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(recordsBucket).Cursor()
+
+		var key, value []byte
+		if filter.BeforeID != 0 {
+			key, value = cursor.Seek(recordKey(filter.BeforeID))
+			if key == nil {
+				// BeforeID is past the last record
+				key, value = cursor.Last()
+			} else {
+				key, value = cursor.Prev()
+			}
+		} else {
+			key, value = cursor.Last()
+		}
+
+		// recording times can go back with the wall clock, so only IDs are used to skip records
+		for ; key != nil && len(result) < limit; key, value = cursor.Prev() {
+			r := Record{}
+			if err := json.Unmarshal(value, &r); err != nil {
+				return err
+			}
+
+			if filter.matches(&r) {
+				result = append(result, r)
+			}
+		}
+
+		return nil
+	})
+	if err == bolt.ErrDatabaseNotOpen {
+		return nil, ErrStoreClosed
+	}
+
+	return result, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
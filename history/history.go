@@ -0,0 +1,137 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Sources of verification records
+const (
+	SOURCE_VERIFY      = "verify"
+	SOURCE_TRANSACTION = "transaction"
+	SOURCE_WATCHER     = "watcher"
+)
+
+const (
+	// number of records returned by a query without a limit
+	DefaultQueryLimit = 100
+	// maximum number of records returned by a query
+	MaxQueryLimit = 1000
+)
+
+var ErrStoreClosed = errors.New("history store is closed")
+
+// Record is the outcome of a single report verification
+type Record struct {
+	// assigned by the store, increases with every added record
+	ID uint64 `json:"id"`
+	// ID of the API request that verified the report, empty for the watcher
+	RequestID string `json:"requestId,omitempty"`
+	// one of the SOURCE_ values
+	Source string `json:"source"`
+	// Aleo transaction and transition with the oracle update, only for on-chain updates
+	TransactionID string `json:"transactionId,omitempty"`
+	TransitionID  string `json:"transitionId,omitempty"`
+	ReportType    string `json:"reportType"`
+	// hex-encoded SHA-256 hash of the report bytes
+	ReportHash string `json:"reportHash,omitempty"`
+	// label and source of the measurement set that the report matched
+	MatchedMeasurements string `json:"matchedMeasurements,omitempty"`
+	MeasurementsSource  string `json:"measurementsSource,omitempty"`
+	// URL and timestamp of the attested request
+	Url                  string `json:"url,omitempty"`
+	AttestationTimestamp int64  `json:"attestationTimestamp,omitempty"`
+	// time as of which the report was verified
+	ReferenceTime time.Time `json:"referenceTime"`
+	Valid         bool      `json:"valid"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	ErrorMessage  string    `json:"errorMessage,omitempty"`
+	// time when the record was added, set by the store
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// Filter selects records in a query. Zero values match all records
+type Filter struct {
+	// records added at or after this time
+	From *time.Time `json:"from,omitempty"`
+	// records added before this time
+	To         *time.Time `json:"to,omitempty"`
+	Url        string     `json:"url,omitempty"`
+	ReportType string     `json:"reportType,omitempty"`
+	Valid      *bool      `json:"valid,omitempty"`
+	// records with IDs lower than this one, used for pagination
+	BeforeID uint64 `json:"beforeId,omitempty"`
+	// maximum number of records, DefaultQueryLimit if zero, at most MaxQueryLimit
+	Limit int `json:"limit,omitempty"`
+}
+
+// Retention limits the records that a store keeps. The oldest records are removed when new records are added.
+// Zero values keep all records
+type Retention struct {
+	// maximum number of records
+	MaxRecords int
+	// records recorded longer ago are removed
+	MaxAge time.Duration
+}
+
+// returns the oldest recording time that is kept at now, zero if records are kept at any age
+func (r Retention) cutoff(now time.Time) time.Time {
+	if r.MaxAge <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(-r.MaxAge)
+}
+
+// Store keeps verification records. Implementations must be goroutine-safe.
+type Store interface {
+	// Add assigns IDs and recording times to the records and stores them
+	Add(records ...*Record) error
+	// Query returns the records matching the filter, newest first
+	Query(filter *Filter) ([]Record, error)
+	Close() error
+}
+
+// HashReport returns the hex-encoded SHA-256 hash of the report for a record
+func HashReport(report []byte) string {
+	hash := sha256.Sum256(report)
+	return hex.EncodeToString(hash[:])
+}
+
+func (f *Filter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+
+	return min(f.Limit, MaxQueryLimit)
+}
+
+func (f *Filter) matches(r *Record) bool {
+	if f.BeforeID != 0 && r.ID >= f.BeforeID {
+		return false
+	}
+
+	if f.From != nil && r.RecordedAt.Before(*f.From) {
+		return false
+	}
+
+	if f.To != nil && !r.RecordedAt.Before(*f.To) {
+		return false
+	}
+
+	if f.Url != "" && r.Url != f.Url {
+		return false
+	}
+
+	if f.ReportType != "" && r.ReportType != f.ReportType {
+		return false
+	}
+
+	if f.Valid != nil && r.Valid != *f.Valid {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,230 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openStores(t *testing.T, retention Retention) map[string]Store {
+	t.Helper()
+
+	bolt, err := OpenBoltStore(filepath.Join(t.TempDir(), "history.db"), retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		bolt.Close()
+	})
+
+	return map[string]Store{
+		"memory": NewMemoryStore(retention),
+		"bolt":   bolt,
+	}
+}
+
+func ids(records []Record) []uint64 {
+	result := make([]uint64, len(records))
+	for i, r := range records {
+		result[i] = r.ID
+	}
+
+	return result
+}
+
+func TestStoreQuery(t *testing.T) {
+	valid, invalid := true, false
+
+	for name, store := range openStores(t, Retention{}) {
+		t.Run(name, func(t *testing.T) {
+			err := store.Add(
+				&Record{Source: SOURCE_VERIFY, ReportType: "sgx", Url: "a.com", Valid: true},
+				&Record{Source: SOURCE_VERIFY, ReportType: "nitro", Url: "b.com", Valid: false},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			between := time.Now().UTC()
+			time.Sleep(5 * time.Millisecond)
+
+			err = store.Add(
+				&Record{Source: SOURCE_WATCHER, ReportType: "sgx", Url: "b.com", Valid: true},
+				&Record{Source: SOURCE_TRANSACTION, ReportType: "sgx", Url: "a.com", Valid: false},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name   string
+				filter Filter
+				want   []uint64
+			}{
+				{name: "all", want: []uint64{4, 3, 2, 1}},
+				{name: "limit", filter: Filter{Limit: 3}, want: []uint64{4, 3, 2}},
+				{name: "before id", filter: Filter{BeforeID: 3}, want: []uint64{2, 1}},
+				{name: "before id past the end", filter: Filter{BeforeID: 10}, want: []uint64{4, 3, 2, 1}},
+				{name: "url", filter: Filter{Url: "a.com"}, want: []uint64{4, 1}},
+				{name: "report type", filter: Filter{ReportType: "nitro"}, want: []uint64{2}},
+				{name: "valid", filter: Filter{Valid: &valid}, want: []uint64{3, 1}},
+				{name: "invalid", filter: Filter{Valid: &invalid, Url: "a.com"}, want: []uint64{4}},
+				{name: "from", filter: Filter{From: &between}, want: []uint64{4, 3}},
+				{name: "to", filter: Filter{To: &between}, want: []uint64{2, 1}},
+				{name: "no match", filter: Filter{Url: "c.com"}, want: []uint64{}},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					records, err := store.Query(&tt.filter)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					got := ids(records)
+					if len(got) != len(tt.want) {
+						t.Fatalf("expected records %v, got %v", tt.want, got)
+					}
+					for i := range got {
+						if got[i] != tt.want[i] {
+							t.Fatalf("expected records %v, got %v", tt.want, got)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention Retention
+		// records added in every batch, with a pause between the batches
+		batches []int
+		want    []uint64
+	}{
+		{
+			name:      "max records",
+			retention: Retention{MaxRecords: 3},
+			batches:   []int{2, 3},
+			want:      []uint64{5, 4, 3},
+		},
+		{
+			name:      "max age",
+			retention: Retention{MaxAge: 50 * time.Millisecond},
+			batches:   []int{2, 1},
+			want:      []uint64{3},
+		},
+		{
+			name:      "max records and max age",
+			retention: Retention{MaxRecords: 2, MaxAge: 50 * time.Millisecond},
+			batches:   []int{1, 3},
+			want:      []uint64{4, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, store := range openStores(t, tt.retention) {
+				t.Run(name, func(t *testing.T) {
+					for idx, batch := range tt.batches {
+						if idx > 0 {
+							time.Sleep(100 * time.Millisecond)
+						}
+
+						records := make([]*Record, batch)
+						for i := range records {
+							records[i] = &Record{ReportType: "sgx"}
+						}
+
+						if err := store.Add(records...); err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					records, err := store.Query(&Filter{})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					got := ids(records)
+					if len(got) != len(tt.want) {
+						t.Fatalf("expected records %v, got %v", tt.want, got)
+					}
+					for i := range got {
+						if got[i] != tt.want[i] {
+							t.Fatalf("expected records %v, got %v", tt.want, got)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestBoltStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	store, err := OpenBoltStore(path, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Add(&Record{ReportType: "sgx", ReportHash: HashReport([]byte("report")), Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	if err = store.Add(&Record{}); err != ErrStoreClosed {
+		t.Errorf("expected %v after close, got %v", ErrStoreClosed, err)
+	}
+
+	store, err = OpenBoltStore(path, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err = store.Add(&Record{ReportType: "nitro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.Query(&Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[0].ID != 2 || records[1].ID != 1 {
+		t.Fatalf("unexpected records after reopening: %+v", records)
+	}
+
+	if records[1].ReportHash != HashReport([]byte("report")) || !records[1].Valid || records[1].RecordedAt.IsZero() {
+		t.Errorf("record was not restored: %+v", records[1])
+	}
+}
+
+func TestMemoryStoreQueryAfterClockStep(t *testing.T) {
+	store := NewMemoryStore(Retention{})
+
+	if err := store.Add(&Record{}, &Record{}, &Record{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wall clock went back by an hour after the first record
+	start := time.Now().UTC()
+	store.records[0].RecordedAt = start.Add(time.Hour)
+	store.records[1].RecordedAt = start
+	store.records[2].RecordedAt = start.Add(time.Second)
+
+	from := start.Add(30 * time.Minute)
+	records, err := store.Query(&Filter{From: &from})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(records); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected records [1], got %v", got)
+	}
+}
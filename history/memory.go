@@ -0,0 +1,95 @@
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store, the records are lost after a restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	retention Retention
+	records   []Record
+	lastID    uint64
+	closed    bool
+}
+
+func NewMemoryStore(retention Retention) *MemoryStore {
+	return &MemoryStore{retention: retention}
+}
+
+func (s *MemoryStore) Add(records ...*Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	now := time.Now().UTC()
+	for _, r := range records {
+		s.lastID++
+		r.ID = s.lastID
+		r.RecordedAt = now
+		s.records = append(s.records, *r)
+	}
+
+	s.removeOld(now)
+
+	return nil
+}
+
+// removes the records that the retention doesn't keep. Must be called with the lock held
+func (s *MemoryStore) removeOld(now time.Time) {
+	drop := 0
+	if s.retention.MaxRecords > 0 && len(s.records) > s.retention.MaxRecords {
+		drop = len(s.records) - s.retention.MaxRecords
+	}
+
+	cutoff := s.retention.cutoff(now)
+	for drop < len(s.records) && s.records[drop].RecordedAt.Before(cutoff) {
+		drop++
+	}
+
+	// the dropped records are freed when append moves the records to a new array
+	s.records = s.records[drop:]
+}
+
+func (s *MemoryStore) Query(filter *Filter) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	limit := filter.limit()
+	result := make([]Record, 0, min(limit, len(s.records)))
+
+	// recording times can go back with the wall clock, so only IDs are used to skip records
+	end := len(s.records)
+	if filter.BeforeID != 0 {
+		end = sort.Search(len(s.records), func(idx int) bool {
+			return s.records[idx].ID >= filter.BeforeID
+		})
+	}
+
+	for idx := end - 1; idx >= 0 && len(result) < limit; idx-- {
+		r := &s.records[idx]
+		if filter.matches(r) {
+			result = append(result, *r)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
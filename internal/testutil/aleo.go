@@ -0,0 +1,54 @@
+package testutil
+
+import (
+	"errors"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+// FakeSession is an Aleo wrapper session that doesn't run the WASM module. It "formats" a message by returning it as it is,
+// and uses the first 16 bytes of a message as its hash
+type FakeSession struct {
+	aleo_wrapper.Session
+
+	// makes HashMessage fail, like a session that stopped working
+	Broken bool
+	Closed bool
+}
+
+func (s *FakeSession) FormatMessage(message []byte, targetChunks int) ([]byte, error) {
+	return message, nil
+}
+
+func (s *FakeSession) HashMessage(message []byte) ([]byte, error) {
+	if s.Broken {
+		return nil, errors.New("broken session")
+	}
+
+	hash := make([]byte, 16)
+	copy(hash, message)
+	return hash, nil
+}
+
+func (s *FakeSession) Close() {
+	s.Closed = true
+}
+
+// FakeWrapper creates FakeSessions
+type FakeWrapper struct {
+	aleo_wrapper.Wrapper
+
+	// number of created sessions
+	Created int
+	// makes NewSession fail
+	Fail bool
+}
+
+func (w *FakeWrapper) NewSession() (aleo_wrapper.Session, error) {
+	if w.Fail {
+		return nil, errors.New("cannot create session")
+	}
+
+	w.Created++
+	return &FakeSession{}, nil
+}
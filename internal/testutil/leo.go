@@ -0,0 +1,48 @@
+// Package testutil has fixtures and fakes shared by the tests of other packages
+package testutil
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/zkportal/oracle-verification-backend/leo"
+)
+
+// maximum number of chunks in a message and of u128 values in a chunk
+const maxMessageChunks = 32
+
+// LeoMessage formats the bytes as a message struct like in the oracle update transition inputs, see leo.MessageBytes.
+// The last u128 value is padded with zero bytes, an empty message has a single zero value because Leo structs cannot be empty.
+// Unlike aleo_utils FormatMessage, the chunks are not padded to 32 values, so leo.ParseMessage returns the message
+// without more padding. Panics if the message is longer than 32 chunks
+func LeoMessage(message []byte) string {
+	numValues := max((len(message)+15)/16, 1)
+	if numValues > maxMessageChunks*maxMessageChunks {
+		panic(fmt.Sprintf("testutil: message is longer than %d bytes", maxMessageChunks*maxMessageChunks*16))
+	}
+
+	result := new(leo.Struct)
+
+	for valueIdx := 0; valueIdx < numValues; valueIdx++ {
+		chunkIdx := valueIdx / maxMessageChunks
+		if chunkIdx == len(result.Members) {
+			result.Members = append(result.Members, leo.Member{Name: "c" + strconv.Itoa(chunkIdx), Value: new(leo.Struct)})
+		}
+
+		// u128 values are little-endian
+		buf := make([]byte, 16)
+		copy(buf, message[valueIdx*16:])
+		for left, right := 0, len(buf)-1; left < right; left, right = left+1, right-1 {
+			buf[left], buf[right] = buf[right], buf[left]
+		}
+
+		chunk := result.Members[chunkIdx].Value.(*leo.Struct)
+		chunk.Members = append(chunk.Members, leo.Member{
+			Name:  "f" + strconv.Itoa(valueIdx%maxMessageChunks),
+			Value: &leo.Integer{Type: leo.TYPE_U128, Value: new(big.Int).SetBytes(buf)},
+		})
+	}
+
+	return result.String()
+}
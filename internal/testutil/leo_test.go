@@ -0,0 +1,107 @@
+package testutil
+
+import (
+	"bytes"
+	"testing"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+	"github.com/zkportal/oracle-verification-backend/leo"
+)
+
+func TestLeoMessage(t *testing.T) {
+	for _, size := range []int{0, 1, 16, 17, 512, 513, 16384} {
+		message := make([]byte, size)
+		for idx := range message {
+			message[idx] = byte(idx + 1)
+		}
+
+		got, err := leo.ParseMessage(LeoMessage(message))
+		if err != nil {
+			t.Fatalf("ParseMessage() of a %d byte message unexpected error = %v", size, err)
+		}
+
+		// the last value is padded, an empty message has one value
+		want := append(message, make([]byte, (16-size%16)%16)...)
+		if size == 0 {
+			want = make([]byte, 16)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ParseMessage(LeoMessage()) of %d bytes = %v, want %v", size, got, want)
+		}
+	}
+}
+
+// checks the message format against the format of the oracle contract
+func TestLeoMessageAleoUtils(t *testing.T) {
+	wrapper, closeFn, err := aleo_wrapper.NewWrapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	session, err := wrapper.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{name: "empty message", size: 0, chunks: 1},
+		{name: "partial value", size: 40, chunks: 1},
+		{name: "one chunk", size: 512, chunks: 1},
+		{name: "partial chunk", size: 700, chunks: 2},
+		{name: "report data size", size: 8 * 512, chunks: 8},
+		{name: "longest message", size: 32 * 512, chunks: 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := make([]byte, tt.size)
+			for idx := range message {
+				message[idx] = byte(idx*7 + 1)
+			}
+
+			// aleo_utils pads the message to whole chunks
+			padded := append(message, make([]byte, tt.chunks*512-tt.size)...)
+
+			formatted, err := session.FormatMessage(message, tt.chunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := leo.ParseMessage(string(formatted))
+			if err != nil {
+				t.Fatalf("ParseMessage() unexpected error = %v", err)
+			}
+			if !bytes.Equal(parsed, padded) {
+				t.Errorf("ParseMessage(FormatMessage()) = %v, want %v", parsed, padded)
+			}
+
+			ours := []byte(LeoMessage(padded))
+
+			recovered, err := session.RecoverMessage(ours)
+			if err != nil {
+				t.Fatalf("RecoverMessage(LeoMessage()) unexpected error = %v", err)
+			}
+			if !bytes.Equal(recovered, padded) {
+				t.Errorf("RecoverMessage(LeoMessage()) = %v, want %v", recovered, padded)
+			}
+
+			wantHash, err := session.HashMessage(formatted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotHash, err := session.HashMessage(ours)
+			if err != nil {
+				t.Fatalf("HashMessage(LeoMessage()) unexpected error = %v", err)
+			}
+			if !bytes.Equal(gotHash, wantHash) {
+				t.Errorf("HashMessage(LeoMessage()) = %x, want the hash of FormatMessage() %x", gotHash, wantHash)
+			}
+		})
+	}
+}
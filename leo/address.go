@@ -0,0 +1,63 @@
+package leo
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	addressPrefix = "aleo"
+	// "aleo1" followed by 52 characters of data and 6 characters of checksum
+	addressLength = 63
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	// Aleo addresses use the bech32m checksum
+	bech32mConstant = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// checks the length, the character set and the checksum of an address
+func validateAddress(address string) error {
+	if len(address) != addressLength {
+		return fmt.Errorf("%w: %s must be %d characters", ErrInvalidAddress, address, addressLength)
+	}
+
+	// the human-readable part expanded for the checksum, followed by the data
+	values := make([]byte, 0, 2*len(addressPrefix)+1+len(address)-len(addressPrefix)-1)
+	for _, c := range []byte(addressPrefix) {
+		values = append(values, c>>5)
+	}
+	values = append(values, 0)
+	for _, c := range []byte(addressPrefix) {
+		values = append(values, c&31)
+	}
+
+	for _, c := range address[len(addressPrefix)+1:] {
+		idx := strings.IndexRune(bech32Charset, c)
+		if idx == -1 {
+			return fmt.Errorf("%w: %s has invalid character %q", ErrInvalidAddress, address, c)
+		}
+		values = append(values, byte(idx))
+	}
+
+	if bech32Polymod(values) != bech32mConstant {
+		return fmt.Errorf("%w: %s has invalid checksum", ErrInvalidAddress, address)
+	}
+
+	return nil
+}
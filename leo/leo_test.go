@@ -0,0 +1,226 @@
+package leo
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "u8", input: "255u8", want: "255u8"},
+		{name: "u16", input: "65535u16", want: "65535u16"},
+		{name: "u32", input: "4294967295u32", want: "4294967295u32"},
+		{name: "u64", input: "18446744073709551615u64", want: "18446744073709551615u64"},
+		{name: "u128", input: "340282366920938463463374607431768211455u128", want: "340282366920938463463374607431768211455u128"},
+		{name: "i8 min", input: "-128i8", want: "-128i8"},
+		{name: "i8 max", input: "127i8", want: "127i8"},
+		{name: "i16", input: "-32768i16", want: "-32768i16"},
+		{name: "i32", input: "2147483647i32", want: "2147483647i32"},
+		{name: "i64", input: "-9223372036854775808i64", want: "-9223372036854775808i64"},
+		{name: "i128", input: "170141183460469231731687303715884105727i128", want: "170141183460469231731687303715884105727i128"},
+		{name: "underscores", input: "1_000_000u32", want: "1000000u32"},
+		{name: "leading zeros", input: "007u8", want: "7u8"},
+		{name: "field", input: "8444461749428370424248824938781546531375899335154063827935233455917409239040field", want: "8444461749428370424248824938781546531375899335154063827935233455917409239040field"},
+		{name: "negative field", input: "-1field", want: "8444461749428370424248824938781546531375899335154063827935233455917409239040field"},
+		{name: "group", input: "0group", want: "0group"},
+		{name: "scalar", input: "2111115437357092606062206234695386632838870926408408195193685246394721360382scalar", want: "2111115437357092606062206234695386632838870926408408195193685246394721360382scalar"},
+		{name: "true", input: "true", want: "true"},
+		{name: "false", input: " false\n", want: "false"},
+		{name: "address", input: "aleo1skjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuersv", want: "aleo1skjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuersv"},
+		{name: "public visibility", input: "5u32.public", want: "5u32"},
+		{name: "private visibility", input: "{ a: 5u32.private }", want: "{ a: 5u32 }"},
+		{name: "struct", input: "{\n  chunk_1: 1u128,\n  chunk_2: 2u128\n}", want: "{ chunk_1: 1u128, chunk_2: 2u128 }"},
+		{name: "single line struct", input: "{chunk_1:1u128,chunk_2:2u128}", want: "{ chunk_1: 1u128, chunk_2: 2u128 }"},
+		{name: "nested struct", input: "{ c0: { f0: 1u128, f1: 2u128 }, c1: { f0: 3u128 } }", want: "{ c0: { f0: 1u128, f1: 2u128 }, c1: { f0: 3u128 } }"},
+		{name: "array", input: "[1u8, 2u8,\n 3u8]", want: "[ 1u8, 2u8, 3u8 ]"},
+		{name: "nested array", input: "[[true, false], [false, true]]", want: "[ [ true, false ], [ false, true ] ]"},
+		{name: "struct with array", input: "{ owner: aleo1l4xyshuw6mvpxdx35cws7djlnemwranp4s8acgdm9k8ev5u9ugzsfklmqq, values: [1field, 2group, 3scalar] }", want: "{ owner: aleo1l4xyshuw6mvpxdx35cws7djlnemwranp4s8acgdm9k8ev5u9ugzsfklmqq, values: [ 1field, 2group, 3scalar ] }"},
+		{name: "comments", input: "{\n  // first\n  a: 1u8, /* second */ b: 2u8\n}", want: "{ a: 1u8, b: 2u8 }"},
+		{name: "u8 overflow", input: "256u8", wantErr: ErrOutOfRange},
+		{name: "i8 underflow", input: "-129i8", wantErr: ErrOutOfRange},
+		{name: "negative unsigned", input: "-1u64", wantErr: ErrOutOfRange},
+		{name: "u128 overflow", input: "340282366920938463463374607431768211456u128", wantErr: ErrOutOfRange},
+		{name: "field overflow", input: "8444461749428370424248824938781546531375899335154063827935233455917409239041field", wantErr: ErrOutOfRange},
+		{name: "scalar overflow", input: "2111115437357092606062206234695386632838870926408408195193685246394721360383scalar", wantErr: ErrOutOfRange},
+		{name: "address checksum", input: "aleo1skjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuersw", wantErr: ErrInvalidAddress},
+		{name: "address length", input: "aleo1skjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuers", wantErr: ErrInvalidAddress},
+		{name: "address charset", input: "aleo1bkjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuersv", wantErr: ErrInvalidAddress},
+		{name: "empty", input: "", wantErr: ErrSyntax},
+		{name: "missing type", input: "5", wantErr: ErrSyntax},
+		{name: "unknown type", input: "5u256", wantErr: ErrSyntax},
+		{name: "leading underscore", input: "_5u8", wantErr: ErrSyntax},
+		{name: "invalid visibility", input: "5u8.secret", wantErr: ErrSyntax},
+		{name: "empty struct", input: "{}", wantErr: ErrSyntax},
+		{name: "empty array", input: "[]", wantErr: ErrSyntax},
+		{name: "trailing comma", input: "{ a: 1u8, }", wantErr: ErrSyntax},
+		{name: "missing colon", input: "{ a 1u8 }", wantErr: ErrSyntax},
+		{name: "missing comma", input: "{ a: 1u8 b: 2u8 }", wantErr: ErrSyntax},
+		{name: "unterminated struct", input: "{ a: 1u8", wantErr: ErrSyntax},
+		{name: "unterminated comment", input: "{ a: 1u8 } /*", wantErr: ErrSyntax},
+		{name: "duplicate member", input: "{ a: 1u8, a: 2u8 }", wantErr: ErrSyntax},
+		{name: "invalid member name", input: "{ 1a: 1u8 }", wantErr: ErrSyntax},
+		{name: "trailing input", input: "1u8 2u8", wantErr: ErrSyntax},
+		{name: "too deep", input: strings.Repeat("[", MaxDepth+1) + "1u8" + strings.Repeat("]", MaxDepth+1), wantErr: ErrSyntax},
+		{name: "max depth", input: strings.Repeat("[", MaxDepth) + "1u8" + strings.Repeat("]", MaxDepth), want: strings.Repeat("[ ", MaxDepth) + "1u8" + strings.Repeat(" ]", MaxDepth)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	value, err := Parse("{ a: 1u8, b: { c: [true] }, d: aleo1skjdmt9s743jlgf378n38hud4jdnmf4tafsymsj8ta2hqmcc5qxqeuersv, e: 1field, f: 1group, g: 1scalar }")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := value.(*Struct)
+	if !ok {
+		t.Fatalf("expected *Struct, got %T", value)
+	}
+
+	want := map[string]string{
+		"a": TYPE_U8,
+		"b": "struct",
+		"d": TYPE_ADDRESS,
+		"e": TYPE_FIELD,
+		"f": TYPE_GROUP,
+		"g": TYPE_SCALAR,
+	}
+	for name, wantType := range want {
+		member, ok := s.Get(name)
+		if !ok {
+			t.Fatalf("member %s is missing", name)
+		}
+		if TypeOf(member) != wantType {
+			t.Errorf("member %s type = %s, want %s", name, TypeOf(member), wantType)
+		}
+	}
+
+	b, _ := s.Get("b")
+	c, _ := b.(*Struct).Get("c")
+	if arr, ok := c.(Array); !ok || len(arr) != 1 || arr[0] != Boolean(true) {
+		t.Errorf("member b.c = %v, want [ true ]", c)
+	}
+
+	if _, ok := s.Get("z"); ok {
+		t.Error("Get() found a member that doesn't exist")
+	}
+
+	if _, err = s.Integer("a", TYPE_U8); err != nil {
+		t.Errorf("Integer() unexpected error = %v", err)
+	}
+	if _, err = s.Integer("a", TYPE_U128); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("Integer() error = %v, want %v", err, ErrUnexpectedType)
+	}
+	if _, err = s.Integer("e", TYPE_U8); !errors.Is(err, ErrUnexpectedType) {
+		t.Errorf("Integer() error = %v, want %v", err, ErrUnexpectedType)
+	}
+	if _, err = s.Integer("z", TYPE_U8); !errors.Is(err, ErrNoMember) {
+		t.Errorf("Integer() error = %v, want %v", err, ErrNoMember)
+	}
+}
+
+func TestIntegerLittleEndian(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []byte
+	}{
+		{name: "u8", input: "5u8", want: []byte{5}},
+		{name: "u16", input: "258u16", want: []byte{2, 1}},
+		{name: "u32", input: "16909060u32", want: []byte{4, 3, 2, 1}},
+		{name: "u128", input: "129127208515966861317u128", want: []byte{5, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "i8 negative", input: "-1i8", want: []byte{0xff}},
+		{name: "i16 min", input: "-32768i16", want: []byte{0, 0x80}},
+		{name: "i32 negative", input: "-2i32", want: []byte{0xfe, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := value.(*Integer).LittleEndian()
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("LittleEndian() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr error
+	}{
+		{
+			name:  "single chunk",
+			input: "{ c0: { f0: 129127208515966861317u128, f1: 0u128 } }",
+			want:  []byte{5, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:  "multiple chunks on multiple lines",
+			input: "{\n  c0: {\n    f0: 1u128\n  },\n  c1: {\n    f0: 2u128.public\n  }\n}",
+			want:  []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{name: "not a struct", input: "1u128", wantErr: ErrUnexpectedType},
+		{name: "chunk out of order", input: "{ c1: { f0: 1u128 }, c0: { f0: 1u128 } }", wantErr: ErrUnexpectedType},
+		{name: "value out of order", input: "{ c0: { f1: 1u128, f0: 1u128 } }", wantErr: ErrUnexpectedType},
+		{name: "chunk not a struct", input: "{ c0: 1u128 }", wantErr: ErrUnexpectedType},
+		{name: "value not u128", input: "{ c0: { f0: 1u64 } }", wantErr: ErrUnexpectedType},
+		{name: "too many values", input: "{ c0: { " + messageValues(33) + " } }", wantErr: ErrOutOfRange},
+		{name: "invalid syntax", input: "{ c0: { f0: 1u128 }", wantErr: ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMessage(tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseMessage() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMessage() unexpected error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ParseMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// returns n u128 members of a message chunk, named f0, f1, ...
+func messageValues(n int) string {
+	values := make([]string, n)
+	for idx := range values {
+		values[idx] = "f" + strconv.Itoa(idx) + ": 1u128"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,65 @@
+package leo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maximum number of chunks in a formatted message and of u128 values in a chunk
+const maxMessageChunks = 32
+
+// MessageBytes returns the bytes of a message formatted as a Leo struct of up to 32 structs of up to 32 u128 values,
+// like the ReportData struct. Chunks must be named c0, c1, ... and their values f0, f1, ...
+// Every u128 value is 16 little-endian bytes of the message.
+func MessageBytes(value Value) ([]byte, error) {
+	message, ok := value.(*Struct)
+	if !ok {
+		return nil, fmt.Errorf("%w: message must be a struct, got %s", ErrUnexpectedType, TypeOf(value))
+	}
+
+	if len(message.Members) > maxMessageChunks {
+		return nil, fmt.Errorf("%w: message has more than %d chunks", ErrOutOfRange, maxMessageChunks)
+	}
+
+	buf := make([]byte, 0, len(message.Members)*maxMessageChunks*16)
+
+	for chunkIdx, member := range message.Members {
+		if member.Name != "c"+strconv.Itoa(chunkIdx) {
+			return nil, fmt.Errorf("%w: message chunk %d must be named c%d, got %s", ErrUnexpectedType, chunkIdx, chunkIdx, member.Name)
+		}
+
+		chunk, ok := member.Value.(*Struct)
+		if !ok {
+			return nil, fmt.Errorf("%w: message chunk %s must be a struct, got %s", ErrUnexpectedType, member.Name, TypeOf(member.Value))
+		}
+
+		if len(chunk.Members) > maxMessageChunks {
+			return nil, fmt.Errorf("%w: message chunk %s has more than %d values", ErrOutOfRange, member.Name, maxMessageChunks)
+		}
+
+		for valueIdx, chunkMember := range chunk.Members {
+			if chunkMember.Name != "f"+strconv.Itoa(valueIdx) {
+				return nil, fmt.Errorf("%w: value %d of message chunk %s must be named f%d, got %s", ErrUnexpectedType, valueIdx, member.Name, valueIdx, chunkMember.Name)
+			}
+
+			number, err := chunk.Integer(chunkMember.Name, TYPE_U128)
+			if err != nil {
+				return nil, fmt.Errorf("message chunk %s: %w", member.Name, err)
+			}
+
+			buf = append(buf, number.LittleEndian()...)
+		}
+	}
+
+	return buf, nil
+}
+
+// ParseMessage parses a message formatted as a Leo struct and returns its bytes, see MessageBytes
+func ParseMessage(input string) ([]byte, error) {
+	value, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return MessageBytes(value)
+}
@@ -0,0 +1,357 @@
+package leo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// maximum nesting of structs and arrays, same as in snarkVM
+const MaxDepth = 32
+
+var (
+	ErrSyntax         = errors.New("leo: syntax error")
+	ErrOutOfRange     = errors.New("leo: value is out of range")
+	ErrInvalidAddress = errors.New("leo: invalid address")
+	ErrUnexpectedType = errors.New("leo: unexpected type")
+	ErrNoMember       = errors.New("leo: struct has no member")
+)
+
+// modulus of the base field of the Aleo curve, used by field and group values
+var fieldModulus, _ = new(big.Int).SetString("8444461749428370424248824938781546531375899335154063827935233455917409239041", 10)
+
+// modulus of the scalar field of the Aleo curve
+var scalarModulus, _ = new(big.Int).SetString("2111115437357092606062206234695386632838870926408408195193685246394721360383", 10)
+
+type parser struct {
+	input string
+	pos   int
+}
+
+// Parse parses an Aleo plaintext value: a literal, a struct or an array. The value can span any number of lines
+// and have comments. Visibility suffixes of literals, e.g. ".public", are accepted and dropped.
+// Group values are not checked to be on the curve.
+func Parse(input string) (Value, error) {
+	p := &parser{input: input}
+
+	value, err := p.parseValue(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.skipSpace(); err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.input) {
+		return nil, p.errorf("unexpected %q after value", p.input[p.pos])
+	}
+
+	return value, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, p.pos, fmt.Sprintf(format, args...))
+}
+
+// skips whitespace and comments
+func (p *parser) skipSpace() error {
+	for p.pos < len(p.input) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.input[p.pos:], "//"):
+			end := strings.IndexByte(p.input[p.pos:], '\n')
+			if end == -1 {
+				p.pos = len(p.input)
+			} else {
+				p.pos += end + 1
+			}
+		case strings.HasPrefix(p.input[p.pos:], "/*"):
+			end := strings.Index(p.input[p.pos+2:], "*/")
+			if end == -1 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += end + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// skips whitespace and consumes the character if it's next
+func (p *parser) consume(c byte) (bool, error) {
+	if err := p.skipSpace(); err != nil {
+		return false, err
+	}
+
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (p *parser) expect(c byte) error {
+	ok, err := p.consume(c)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if p.pos == len(p.input) {
+			return p.errorf("expected %q, got end of input", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.input[p.pos])
+	}
+
+	return nil
+}
+
+func (p *parser) parseValue(depth int) (Value, error) {
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+
+	if p.pos == len(p.input) {
+		return nil, p.errorf("expected value, got end of input")
+	}
+
+	switch p.input[p.pos] {
+	case '{', '[':
+		if depth == MaxDepth {
+			return nil, p.errorf("value is nested deeper than %d levels", MaxDepth)
+		}
+
+		if p.input[p.pos] == '{' {
+			return p.parseStruct(depth + 1)
+		}
+		return p.parseArray(depth + 1)
+	default:
+		return p.parseLiteral()
+	}
+}
+
+func (p *parser) parseStruct(depth int) (Value, error) {
+	// skip the opening brace
+	p.pos++
+
+	result := new(Struct)
+
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		namePos := p.pos
+		name := p.scanIdentifier()
+		if name == "" {
+			return nil, p.errorf("expected struct member name")
+		}
+
+		if _, exists := result.Get(name); exists {
+			p.pos = namePos
+			return nil, p.errorf("duplicate struct member %s", name)
+		}
+
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Members = append(result.Members, Member{Name: name, Value: value})
+
+		next, err := p.consume(',')
+		if err != nil {
+			return nil, err
+		}
+
+		if !next {
+			break
+		}
+	}
+
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *parser) parseArray(depth int) (Value, error) {
+	// skip the opening bracket
+	p.pos++
+
+	var result Array
+
+	for {
+		value, err := p.parseValue(depth)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, value)
+
+		next, err := p.consume(',')
+		if err != nil {
+			return nil, err
+		}
+
+		if !next {
+			break
+		}
+	}
+
+	if err := p.expect(']'); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scans an identifier, which starts with a letter and has letters, digits and underscores
+func (p *parser) scanIdentifier() string {
+	start := p.pos
+
+	if p.pos == len(p.input) || !isLetter(p.input[p.pos]) {
+		return ""
+	}
+
+	for p.pos < len(p.input) && (isLetter(p.input[p.pos]) || isDigit(p.input[p.pos]) || p.input[p.pos] == '_') {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+func (p *parser) parseLiteral() (Value, error) {
+	start := p.pos
+
+	for p.pos < len(p.input) && (isLetter(p.input[p.pos]) || isDigit(p.input[p.pos]) || p.input[p.pos] == '_' || p.input[p.pos] == '-') {
+		p.pos++
+	}
+
+	literal := p.input[start:p.pos]
+	if literal == "" {
+		return nil, p.errorf("unexpected %q", p.input[p.pos])
+	}
+
+	// visibility of a transition input or output
+	if p.pos < len(p.input) && p.input[p.pos] == '.' {
+		p.pos++
+
+		visibility := p.scanIdentifier()
+		if visibility != "public" && visibility != "private" && visibility != "constant" {
+			return nil, p.errorf("invalid visibility %q", visibility)
+		}
+	}
+
+	value, err := parseLiteral(literal)
+	if err != nil {
+		return nil, fmt.Errorf("%w at offset %d", err, start)
+	}
+
+	return value, nil
+}
+
+func parseLiteral(literal string) (Value, error) {
+	switch {
+	case literal == "true":
+		return Boolean(true), nil
+	case literal == "false":
+		return Boolean(false), nil
+	case strings.HasPrefix(literal, "aleo1"):
+		if err := validateAddress(literal); err != nil {
+			return nil, err
+		}
+		return Address(literal), nil
+	}
+
+	negative := strings.HasPrefix(literal, "-")
+	digits := strings.TrimPrefix(literal, "-")
+
+	// digits can be separated with underscores, but must start with a digit
+	end := 0
+	for end < len(digits) && (isDigit(digits[end]) || (end > 0 && digits[end] == '_')) {
+		end++
+	}
+
+	literalType := digits[end:]
+	digits = strings.ReplaceAll(digits[:end], "_", "")
+
+	if digits == "" {
+		return nil, fmt.Errorf("%w: invalid literal %q", ErrSyntax, literal)
+	}
+
+	value, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		value.Neg(value)
+	}
+
+	switch literalType {
+	case TYPE_FIELD:
+		value, err := reduce(value, fieldModulus, literal)
+		if err != nil {
+			return nil, err
+		}
+		return &Field{Value: value}, nil
+	case TYPE_GROUP:
+		value, err := reduce(value, fieldModulus, literal)
+		if err != nil {
+			return nil, err
+		}
+		return &Group{Value: value}, nil
+	case TYPE_SCALAR:
+		value, err := reduce(value, scalarModulus, literal)
+		if err != nil {
+			return nil, err
+		}
+		return &Scalar{Value: value}, nil
+	}
+
+	bits, ok := integerBits[literalType]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid literal %q", ErrSyntax, literal)
+	}
+
+	integer := &Integer{Type: literalType, Value: value}
+
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if integer.Signed() {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	// the upper bound is exclusive
+	max.Sub(max, big.NewInt(1))
+
+	if value.Cmp(min) < 0 || value.Cmp(max) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrOutOfRange, literal)
+	}
+
+	return integer, nil
+}
+
+// checks that the absolute value is less than the modulus, then maps a negative value to its field element
+func reduce(value, modulus *big.Int, literal string) (*big.Int, error) {
+	if new(big.Int).Abs(value).Cmp(modulus) >= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrOutOfRange, literal)
+	}
+
+	return value.Mod(value, modulus), nil
+}
@@ -0,0 +1,214 @@
+package leo
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Literal types of Aleo plaintext values
+const (
+	TYPE_ADDRESS = "address"
+	TYPE_BOOLEAN = "boolean"
+	TYPE_FIELD   = "field"
+	TYPE_GROUP   = "group"
+	TYPE_SCALAR  = "scalar"
+	TYPE_I8      = "i8"
+	TYPE_I16     = "i16"
+	TYPE_I32     = "i32"
+	TYPE_I64     = "i64"
+	TYPE_I128    = "i128"
+	TYPE_U8      = "u8"
+	TYPE_U16     = "u16"
+	TYPE_U32     = "u32"
+	TYPE_U64     = "u64"
+	TYPE_U128    = "u128"
+)
+
+// size in bits of the integer types
+var integerBits = map[string]int{
+	TYPE_I8:   8,
+	TYPE_I16:  16,
+	TYPE_I32:  32,
+	TYPE_I64:  64,
+	TYPE_I128: 128,
+	TYPE_U8:   8,
+	TYPE_U16:  16,
+	TYPE_U32:  32,
+	TYPE_U64:  64,
+	TYPE_U128: 128,
+}
+
+// Value is a parsed Aleo plaintext value, one of *Struct, Array, *Integer, *Field, *Group, *Scalar, Address or Boolean
+type Value interface {
+	// String formats the value as a single-line Leo value
+	String() string
+}
+
+// Member is a named member of a struct
+type Member struct {
+	Name  string
+	Value Value
+}
+
+// Struct is a Leo struct, members are in the order of the source value
+type Struct struct {
+	Members []Member
+}
+
+// Get returns the value of the struct member with the name
+func (s *Struct) Get(name string) (Value, bool) {
+	for _, m := range s.Members {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Integer returns the struct member with the name if it's an integer of the type
+func (s *Struct) Integer(name, integerType string) (*Integer, error) {
+	value, ok := s.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoMember, name)
+	}
+
+	integer, ok := value.(*Integer)
+	if !ok || integer.Type != integerType {
+		return nil, fmt.Errorf("%w: member %s must be %s, got %s", ErrUnexpectedType, name, integerType, TypeOf(value))
+	}
+
+	return integer, nil
+}
+
+func (s *Struct) String() string {
+	members := make([]string, len(s.Members))
+	for idx, m := range s.Members {
+		members[idx] = m.Name + ": " + m.Value.String()
+	}
+
+	return "{ " + strings.Join(members, ", ") + " }"
+}
+
+// Array is a Leo array
+type Array []Value
+
+func (a Array) String() string {
+	elements := make([]string, len(a))
+	for idx, v := range a {
+		elements[idx] = v.String()
+	}
+
+	return "[ " + strings.Join(elements, ", ") + " ]"
+}
+
+// Integer is a signed or unsigned integer literal, the value is within the range of the type
+type Integer struct {
+	// one of the TYPE_I and TYPE_U values
+	Type  string
+	Value *big.Int
+}
+
+// Bits returns the size of the integer type in bits
+func (i *Integer) Bits() int {
+	return integerBits[i.Type]
+}
+
+// Signed returns true if the integer type is signed
+func (i *Integer) Signed() bool {
+	return strings.HasPrefix(i.Type, "i")
+}
+
+// LittleEndian returns the integer as little-endian bytes of the type's size, negative values in two's complement,
+// which matches the byte representation of integers in snarkVM
+func (i *Integer) LittleEndian() []byte {
+	size := i.Bits() / 8
+
+	value := i.Value
+	if value.Sign() < 0 {
+		value = new(big.Int).Add(value, new(big.Int).Lsh(big.NewInt(1), uint(i.Bits())))
+	}
+
+	buf := make([]byte, size)
+	value.FillBytes(buf)
+
+	for left, right := 0, size-1; left < right; left, right = left+1, right-1 {
+		buf[left], buf[right] = buf[right], buf[left]
+	}
+
+	return buf
+}
+
+func (i *Integer) String() string {
+	return i.Value.String() + i.Type
+}
+
+// Field is an element of the base field of the Aleo curve
+type Field struct {
+	Value *big.Int
+}
+
+func (f *Field) String() string {
+	return f.Value.String() + TYPE_FIELD
+}
+
+// Group is a point on the Aleo curve, represented by its x-coordinate
+type Group struct {
+	Value *big.Int
+}
+
+func (g *Group) String() string {
+	return g.Value.String() + TYPE_GROUP
+}
+
+// Scalar is an element of the scalar field of the Aleo curve
+type Scalar struct {
+	Value *big.Int
+}
+
+func (s *Scalar) String() string {
+	return s.Value.String() + TYPE_SCALAR
+}
+
+// Address is an Aleo address with a valid checksum
+type Address string
+
+func (a Address) String() string {
+	return string(a)
+}
+
+// Boolean is a Leo boolean
+type Boolean bool
+
+func (b Boolean) String() string {
+	if b {
+		return "true"
+	}
+
+	return "false"
+}
+
+// TypeOf returns the literal type of a value, "struct" or "array"
+func TypeOf(value Value) string {
+	switch v := value.(type) {
+	case *Struct:
+		return "struct"
+	case Array:
+		return "array"
+	case *Integer:
+		return v.Type
+	case *Field:
+		return TYPE_FIELD
+	case *Group:
+		return TYPE_GROUP
+	case *Scalar:
+		return TYPE_SCALAR
+	case Address:
+		return TYPE_ADDRESS
+	case Boolean:
+		return TYPE_BOOLEAN
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,272 @@
+package liveCheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/contract"
+)
+
+// Policies for a change of the live contract's measurements
+const (
+	// keep the configured measurements and mark the service as degraded until the contract matches them again
+	POLICY_DEGRADE = "degrade"
+	// replace the target measurements with the contract's measurements
+	POLICY_SWITCH = "switch"
+)
+
+// default time between checks of the live contract
+const DefaultRefreshInterval = 5 * time.Minute
+
+var ErrUnknownPolicy = errors.New("unknown live check policy")
+
+// ValidatePolicy checks that the policy is one of the POLICY_ values
+func ValidatePolicy(policy string) error {
+	if policy != POLICY_DEGRADE && policy != POLICY_SWITCH {
+		return fmt.Errorf("%w \"%s\", expected \"%s\" or \"%s\"", ErrUnknownPolicy, policy, POLICY_DEGRADE, POLICY_SWITCH)
+	}
+
+	return nil
+}
+
+type Config struct {
+	Client       *contract.Client
+	ContractName string
+	// one of the POLICY_ values
+	Policy string
+	// label of the measurement set that the switch policy replaces
+	TargetLabel string
+	// time between checks, DefaultRefreshInterval if zero
+	Interval time.Duration
+}
+
+// Status is the result of the last checks of the live contract
+type Status struct {
+	Program string `json:"program"`
+	Policy  string `json:"policy"`
+
+	// values of the contract's measurement mappings at the last successful check
+	UniqueID  string     `json:"uniqueId,omitempty"`
+	PcrValues []string   `json:"pcrValues,omitempty"`
+	FetchedAt *time.Time `json:"fetchedAt,omitempty"`
+
+	// time and error of the last check, the error is cleared by a successful check
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+
+	// whether the contract's measurements match an accepted measurement set
+	SgxInSync   bool `json:"sgxInSync"`
+	NitroInSync bool `json:"nitroInSync"`
+	Drifted     bool `json:"drifted"`
+	Degraded    bool `json:"degraded"`
+
+	// last time the target measurements were replaced with the contract's measurements
+	SwitchedAt *time.Time `json:"switchedAt,omitempty"`
+}
+
+// Refresher periodically checks the measurements that the live contract asserts against the accepted measurement sets
+type Refresher struct {
+	registry *attestation.MeasurementRegistry
+	conf     Config
+
+	mu     sync.Mutex
+	status Status
+
+	// replaced in tests
+	getUniqueId  func(ctx context.Context, contractName string) (string, error)
+	getPcrValues func(ctx context.Context, contractName string) ([]string, error)
+}
+
+func New(registry *attestation.MeasurementRegistry, conf Config) *Refresher {
+	if conf.Interval == 0 {
+		conf.Interval = DefaultRefreshInterval
+	}
+
+	return &Refresher{
+		registry: registry,
+		conf:     conf,
+		status: Status{
+			Program: conf.ContractName,
+			Policy:  conf.Policy,
+		},
+		getUniqueId:  conf.Client.GetSgxUniqueIDAssert,
+		getPcrValues: conf.Client.GetNitroPcrValuesAssert,
+	}
+}
+
+// Status returns a copy of the current status, nil for a nil refresher
+func (r *Refresher) Status() *Status {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.PcrValues = slices.Clone(r.status.PcrValues)
+
+	return &status
+}
+
+// Run checks the contract every interval until the context is canceled, applying the policy on a change
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Refresh(ctx); err != nil {
+				log.Println("liveCheck: failed to check", r.conf.ContractName, "measurements:", err)
+			}
+		}
+	}
+}
+
+// Check fetches the contract's measurements and compares them to the accepted measurement sets without applying the policy
+func (r *Refresher) Check(ctx context.Context) (*Status, error) {
+	return r.check(ctx, false)
+}
+
+// Refresh fetches the contract's measurements, compares them to the accepted measurement sets, and applies the policy
+func (r *Refresher) Refresh(ctx context.Context) (*Status, error) {
+	return r.check(ctx, true)
+}
+
+func (r *Refresher) check(ctx context.Context, applyPolicy bool) (*Status, error) {
+	now := time.Now().UTC()
+
+	// fetch without the lock so that /info doesn't wait for the node
+	uniqueId, err := r.getUniqueId(ctx, r.conf.ContractName)
+	var pcrValues []string
+	if err == nil {
+		pcrValues, err = r.getPcrValues(ctx, r.conf.ContractName)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.LastAttemptAt = &now
+	if err != nil {
+		r.status.LastError = err.Error()
+		return nil, err
+	}
+
+	if r.status.FetchedAt != nil && (r.status.UniqueID != uniqueId || !slices.Equal(r.status.PcrValues, pcrValues)) {
+		log.Printf("liveCheck: %s measurements changed, SGX Unique ID: %s, Nitro PCR values: %v\n", r.conf.ContractName, uniqueId, pcrValues)
+	}
+
+	r.status.UniqueID = uniqueId
+	r.status.PcrValues = pcrValues
+	r.status.FetchedAt = &now
+	r.status.LastError = ""
+
+	r.compare(now)
+
+	if applyPolicy && r.status.Drifted {
+		r.applyPolicy(now)
+	}
+
+	status := r.status
+	status.PcrValues = slices.Clone(r.status.PcrValues)
+
+	return &status, nil
+}
+
+// compares the fetched measurements to the accepted measurement sets. Must be called with the lock held
+func (r *Refresher) compare(now time.Time) {
+	sets := r.registry.Sets()
+
+	r.status.SgxInSync = slices.ContainsFunc(sets, func(m attestation.MeasurementSet) bool {
+		return m.IsValidAt(now) && m.UniqueID == r.status.UniqueID
+	})
+	r.status.NitroInSync = slices.ContainsFunc(sets, func(m attestation.MeasurementSet) bool {
+		return m.IsValidAt(now) && slices.Equal(m.PcrValues, r.status.PcrValues)
+	})
+
+	r.status.Drifted = !r.status.SgxInSync || !r.status.NitroInSync
+
+	// the contract can return to accepted measurements, e.g. after a rollback
+	if !r.status.Drifted && r.status.Degraded {
+		log.Printf("liveCheck: %s measurements match the accepted measurements again\n", r.conf.ContractName)
+		r.status.Degraded = false
+	}
+}
+
+// applies the policy to drifted measurements. Must be called with the lock held
+func (r *Refresher) applyPolicy(now time.Time) {
+	switch r.conf.Policy {
+	case POLICY_SWITCH:
+		r.registry.Replace(r.switchTarget(now))
+
+		log.Printf("liveCheck: WARNING: switched \"%s\" measurements to the ones of %s, SGX Unique ID: %s, Nitro PCR values: %v\n", r.conf.TargetLabel, r.conf.ContractName, r.status.UniqueID, r.status.PcrValues)
+
+		r.status.SwitchedAt = &now
+		r.status.SgxInSync = true
+		r.status.NitroInSync = true
+		r.status.Drifted = false
+		r.status.Degraded = false
+
+	default:
+		if !r.status.Degraded {
+			log.Printf("liveCheck: WARNING: %s measurements don't match the accepted measurements, the service is degraded\n", r.conf.ContractName)
+		}
+
+		r.status.Degraded = true
+	}
+}
+
+// returns the measurement sets with a new target set that has the contract's measurements in place of the drifted ones
+// and is valid from now on. The previous target set is kept with a new label and is valid until now, so that
+// verifications as of earlier times still use it. The target set is added if there is none
+func (r *Refresher) switchTarget(now time.Time) []attestation.MeasurementSet {
+	sets := slices.Clone(r.registry.Sets())
+
+	target := attestation.MeasurementSet{
+		Label:  r.conf.TargetLabel,
+		Source: attestation.MEASUREMENTS_SOURCE_CONTRACT,
+		// the contract's measurements are accepted from now on
+		NotBefore: &now,
+	}
+
+	idx := slices.IndexFunc(sets, func(m attestation.MeasurementSet) bool {
+		return m.Label == r.conf.TargetLabel
+	})
+	if idx == -1 {
+		sets = slices.Insert(sets, 0, target)
+		idx = 0
+	} else {
+		previous := sets[idx]
+
+		log.Printf("liveCheck: replacing \"%s\" measurements from %s, SGX Unique ID: %s, Nitro PCR values: %v\n", previous.Label, previous.Source, previous.UniqueID, previous.PcrValues)
+
+		target.UniqueID = previous.UniqueID
+		target.PcrValues = previous.PcrValues
+
+		previous.Label = fmt.Sprintf("%s-until-%s", r.conf.TargetLabel, now.Format(time.RFC3339))
+		if previous.NotAfter == nil || previous.NotAfter.After(now) {
+			previous.NotAfter = &now
+		}
+
+		sets[idx] = target
+		sets = slices.Insert(sets, idx+1, previous)
+	}
+
+	if !r.status.SgxInSync {
+		sets[idx].UniqueID = r.status.UniqueID
+	}
+	if !r.status.NitroInSync {
+		sets[idx].PcrValues = slices.Clone(r.status.PcrValues)
+	}
+
+	return sets
+}
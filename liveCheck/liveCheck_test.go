@@ -0,0 +1,178 @@
+package liveCheck
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+)
+
+var (
+	oldPcrValues = []string{"01", "02", "03"}
+	newPcrValues = []string{"04", "05", "06"}
+)
+
+// fakeContract returns the configured measurements instead of querying the node
+type fakeContract struct {
+	uniqueId  string
+	pcrValues []string
+	err       error
+}
+
+func newTestRefresher(policy string, c *fakeContract) (*Refresher, *attestation.MeasurementRegistry) {
+	registry := attestation.NewMeasurementRegistry([]attestation.MeasurementSet{
+		{Label: "target", Source: attestation.MEASUREMENTS_SOURCE_CONFIG, UniqueID: "aa", PcrValues: oldPcrValues},
+		{Label: "other", UniqueID: "bb"},
+	})
+
+	r := New(registry, Config{
+		ContractName: "official_oracle.aleo",
+		Policy:       policy,
+		TargetLabel:  "target",
+	})
+
+	r.getUniqueId = func(ctx context.Context, contractName string) (string, error) {
+		return c.uniqueId, c.err
+	}
+	r.getPcrValues = func(ctx context.Context, contractName string) ([]string, error) {
+		return c.pcrValues, c.err
+	}
+
+	return r, registry
+}
+
+func TestRefresher_InSync(t *testing.T) {
+	c := &fakeContract{uniqueId: "bb", pcrValues: oldPcrValues}
+	r, _ := newTestRefresher(POLICY_DEGRADE, c)
+
+	if r.Status().FetchedAt != nil {
+		t.Fatal("expected no fetch before the first check")
+	}
+
+	status, err := r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.SgxInSync || !status.NitroInSync || status.Drifted || status.Degraded || status.FetchedAt == nil || status.UniqueID != "bb" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestRefresher_Degrade(t *testing.T) {
+	c := &fakeContract{uniqueId: "aa", pcrValues: newPcrValues}
+	r, registry := newTestRefresher(POLICY_DEGRADE, c)
+
+	// Check doesn't apply the policy
+	status, err := r.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.SgxInSync || status.NitroInSync || !status.Drifted || status.Degraded {
+		t.Errorf("unexpected status after check %+v", status)
+	}
+
+	status, err = r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Drifted || !status.Degraded || status.SwitchedAt != nil {
+		t.Errorf("unexpected status after refresh %+v", status)
+	}
+
+	if sets := registry.Sets(); !slices.Equal(sets[0].PcrValues, oldPcrValues) {
+		t.Errorf("degrade policy changed the measurements: %+v", sets)
+	}
+
+	// a failed check keeps the last known state
+	c.err = errors.New("node is down")
+	if _, err = r.Refresh(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if status = r.Status(); !status.Degraded || status.LastError != "node is down" {
+		t.Errorf("unexpected status after a failed check %+v", status)
+	}
+
+	// the contract returns to the accepted measurements
+	c.err = nil
+	c.pcrValues = oldPcrValues
+	status, err = r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Drifted || status.Degraded || status.LastError != "" {
+		t.Errorf("unexpected status after the contract returned %+v", status)
+	}
+}
+
+func TestRefresher_Switch(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour)
+
+	c := &fakeContract{uniqueId: "cc", pcrValues: oldPcrValues}
+	r, registry := newTestRefresher(POLICY_SWITCH, c)
+
+	sets := slices.Clone(registry.Sets())
+	sets[0].NotAfter = &notAfter
+	registry.Replace(sets)
+
+	status, err := r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Drifted || status.Degraded || status.SwitchedAt == nil || !status.SgxInSync {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	switched := registry.Sets()
+	if len(switched) != 3 {
+		t.Fatalf("expected 3 measurement sets, got %+v", switched)
+	}
+
+	target := switched[0]
+	if target.Label != "target" || target.UniqueID != "cc" || !slices.Equal(target.PcrValues, oldPcrValues) || target.Source != attestation.MEASUREMENTS_SOURCE_CONTRACT ||
+		target.NotBefore == nil || !target.NotBefore.Equal(*status.SwitchedAt) || target.NotAfter != nil {
+		t.Errorf("unexpected target set %+v", target)
+	}
+
+	// the previous target set is kept until the switch
+	previous := switched[1]
+	if previous.Label != "target-until-"+status.SwitchedAt.Format(time.RFC3339) || previous.UniqueID != "aa" || previous.Source != attestation.MEASUREMENTS_SOURCE_CONFIG ||
+		previous.NotAfter == nil || !previous.NotAfter.Equal(*status.SwitchedAt) {
+		t.Errorf("unexpected previous target set %+v", previous)
+	}
+
+	// the contract's measurements are not accepted before the switch
+	if target.IsValidAt(status.SwitchedAt.Add(-time.Second)) || !previous.IsValidAt(status.SwitchedAt.Add(-time.Second)) {
+		t.Error("the switched measurements are valid before the switch")
+	}
+
+	// the old slice is not modified
+	if sets[0].UniqueID != "aa" {
+		t.Error("switching modified the previous measurement sets")
+	}
+
+	// the next check is in sync
+	status, err = r.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Drifted {
+		t.Errorf("expected no drift after switching, got %+v", status)
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	for _, policy := range []string{POLICY_DEGRADE, POLICY_SWITCH} {
+		if err := ValidatePolicy(policy); err != nil {
+			t.Errorf("ValidatePolicy(%s) = %v", policy, err)
+		}
+	}
+
+	if err := ValidatePolicy("ignore"); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("expected %v, got %v", ErrUnknownPolicy, err)
+	}
+}
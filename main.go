@@ -1,29 +1,56 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"slices"
 	"strings"
 	"time"
 
 	"github.com/zkportal/oracle-verification-backend/api"
+	"github.com/zkportal/oracle-verification-backend/attestation"
 	"github.com/zkportal/oracle-verification-backend/attestation/nitro"
 	"github.com/zkportal/oracle-verification-backend/config"
 	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/liveCheck"
 	"github.com/zkportal/oracle-verification-backend/reproducibleEnclave"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+	"github.com/zkportal/oracle-verification-backend/watcher"
 
 	aleo_utils "github.com/zkportal/aleo-utils-go"
 )
 
 const (
-	IdleTimeout      = 30
-	ReadWriteTimeout = 5
+	IdleTimeout       = 30
+	ReadHeaderTimeout = 5
 )
 
+func getUniqueIds(measurements []attestation.MeasurementSet) []string {
+	result := make([]string, 0, len(measurements))
+	for _, m := range measurements {
+		if m.HasSgx() {
+			result = append(result, m.UniqueID)
+		}
+	}
+
+	return result
+}
+
+func getPcrValues(measurements []attestation.MeasurementSet) []string {
+	result := make([]string, 0, len(measurements))
+	for _, m := range measurements {
+		if m.HasNitro() {
+			result = append(result, strings.Join(m.PcrValues, ", "))
+		}
+	}
+
+	return result
+}
+
 func main() {
 	confContent, err := os.ReadFile("config.json")
 	if err != nil {
@@ -35,7 +62,9 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	if conf.UniqueIdTarget == "" || len(conf.PcrValuesTarget) != 3 {
+	targetsSource := attestation.MEASUREMENTS_SOURCE_CONFIG
+
+	if len(conf.Measurements) == 0 && (conf.UniqueIdTarget == "" || len(conf.PcrValuesTarget) != 3) {
 		log.Println("One or more enclave measurement targets are not provided (\"uniqueIdTarget\" and \"pcrValuesTarget\" in config.json), reproducing Aleo Oracle backend builds")
 		measurements, err := reproducibleEnclave.GetOracleReproducibleMeasurements()
 		if err != nil {
@@ -44,57 +73,128 @@ func main() {
 
 		conf.UniqueIdTarget = measurements.UniqueID
 		conf.PcrValuesTarget = measurements.PCRs[:]
+		targetsSource = attestation.MEASUREMENTS_SOURCE_REPRODUCIBLE
 	}
 
+	conf.AddTargetMeasurements(targetsSource)
+
+	measurements := attestation.NewMeasurementRegistry(conf.Measurements)
+
+	client, err := contract.NewClient(conf.NodeClientConfig())
+	if err != nil {
+		log.Fatalln("Failed to create Aleo node API client:", err)
+	}
+
+	var refresher *liveCheck.Refresher
 	if !conf.LiveCheck.Skip {
-		log.Println("Requesting SGX Unique ID and Nitro PCR values from", conf.LiveCheck.ContractName, "using", conf.LiveCheck.ApiBaseUrl)
-		liveUniqueId, err := contract.GetSgxUniqueIDAssert(conf.LiveCheck.ApiBaseUrl, conf.LiveCheck.ContractName)
+		refresher = liveCheck.New(measurements, liveCheck.Config{
+			Client:       client,
+			ContractName: conf.LiveCheck.ContractName,
+			Policy:       conf.LiveCheck.OnChange,
+			TargetLabel:  config.TargetMeasurementsLabel,
+			Interval:     time.Duration(conf.LiveCheck.RefreshIntervalSeconds) * time.Second,
+		})
+
+		log.Println("Requesting SGX Unique ID and Nitro PCR values from", conf.LiveCheck.ContractName, "using", strings.Join(client.Endpoints(), ", "))
+		status, err := refresher.Check(context.Background())
 		if err != nil {
-			log.Fatalln("Failed to fetch live contract's SGX Unique ID assertion:", err)
+			log.Fatalln("Failed to fetch live contract's SGX Unique ID and Nitro PCR values assertions:", err)
 		}
 
-		log.Printf("Fetched SGX Unique ID assertion from %s: %s", conf.LiveCheck.ContractName, liveUniqueId)
+		log.Printf("Fetched SGX Unique ID assertion from %s: %s", conf.LiveCheck.ContractName, status.UniqueID)
+		log.Printf("Fetched Nitro PCR values asserttion from %s: %s", conf.LiveCheck.ContractName, strings.Join(status.PcrValues, ", "))
 
-		if liveUniqueId != conf.UniqueIdTarget {
-			log.Fatalf("Reproducible SGX build of the oracle backend produced a different SGX Unique ID than the live contract.\nLive SGX Unique ID: %s\nReproduced SGX Unique ID: %s\n", liveUniqueId, conf.UniqueIdTarget)
+		if !status.SgxInSync {
+			log.Fatalf("None of the enclave measurement sets has the same SGX Unique ID as the live contract.\nLive SGX Unique ID: %s\nConfigured SGX Unique IDs: %s\n", status.UniqueID, strings.Join(getUniqueIds(conf.Measurements), ", "))
 		}
 
-		livePcrValues, err := contract.GetNitroPcrValuesAssert(conf.LiveCheck.ApiBaseUrl, conf.LiveCheck.ContractName)
-		if err != nil {
-			log.Fatalln("Failed to fetch live contract's Nitro PCR values assertion:", err)
+		if !status.NitroInSync {
+			log.Fatalf("None of the enclave measurement sets has the same Nitro PCR values as the live contract.\nLive Nitro PCR values: %s\nConfigured Nitro PCR values: %s\n", strings.Join(status.PcrValues, ", "), strings.Join(getPcrValues(conf.Measurements), "; "))
 		}
 
-		log.Printf("Fetched Nitro PCR values asserttion from %s: %s", conf.LiveCheck.ContractName, liveUniqueId)
-
-		if !slices.Equal(livePcrValues, conf.PcrValuesTarget) {
-			log.Fatalf("Reproducible Nitro build of the oracle backend produced different Nitro PCR values than the live contract.\nLive Nitro PCR values: %s\nReproduced Nitro PCR values: %s\n", strings.Join(livePcrValues, ", "), strings.Join(conf.PcrValuesTarget[:], ", "))
-		}
+		log.Printf("Checking %s every %d seconds, policy on change: %s", conf.LiveCheck.ContractName, conf.LiveCheck.RefreshIntervalSeconds, conf.LiveCheck.OnChange)
+		go refresher.Run(context.Background())
 	} else {
 		log.Println("WARNING: skipping Aleo live contract SGX Unique ID and Nitro PCR values check")
 	}
 
-	log.Println("Expecting Aleo Oracle backend to have SGX Unique ID:", conf.UniqueIdTarget)
-	log.Println("Expecting Aleo Oracle backend to have Nitro PCR values:", strings.Join(conf.PcrValuesTarget, ", "))
+	for _, m := range conf.Measurements {
+		if m.HasSgx() {
+			log.Printf("Expecting Aleo Oracle backend to have SGX Unique ID (%s, %s): %s", m.Label, m.Source, m.UniqueID)
+		}
+		if m.HasNitro() {
+			log.Printf("Expecting Aleo Oracle backend to have Nitro PCR values (%s, %s): %s", m.Label, m.Source, strings.Join(m.PcrValues, ", "))
+		}
+	}
 
 	err = nitro.Init()
 	if err != nil {
 		log.Fatalln("Failed to initialize Nitro report verifier:", err)
 	}
 
+	err = attestation.SetPriceFeeds(conf.PriceFeeds)
+	if err != nil {
+		log.Fatalln("Failed to register price feeds:", err)
+	}
+
+	for _, feed := range conf.PriceFeeds {
+		log.Printf("Registered price feed \"%s\" with tag %d", feed.Url, feed.Tag)
+	}
+
 	aleo, close, err := aleo_utils.NewWrapper()
 	if err != nil {
 		log.Fatalln("Failed to initialize Aleo wrapper:", err)
 	}
 	defer close()
 
-	mux := api.CreateApi(aleo, conf)
+	pool, err := sessionPool.NewPool(aleo, conf.AleoSessionPoolSize)
+	if err != nil {
+		log.Fatalln("Failed to create Aleo session pool:", err)
+	}
+	defer pool.Close()
+
+	var historyStore history.Store
+	if conf.History.File != "" {
+		historyStore, err = history.OpenBoltStore(conf.History.File, conf.HistoryRetention())
+		if err != nil {
+			log.Fatalln("Failed to open verification history:", err)
+		}
+		log.Println("Keeping verification history in", conf.History.File)
+	} else {
+		historyStore = history.NewMemoryStore(conf.HistoryRetention())
+		log.Println("WARNING: history \"file\" is not configured, the verification history will be lost after a restart")
+	}
+	defer historyStore.Close()
+
+	if conf.Watcher.Enable {
+		var cursor watcher.CursorStore = watcher.NewMemoryCursorStore()
+		if conf.Watcher.CursorFile != "" {
+			cursor = watcher.NewFileCursorStore(conf.Watcher.CursorFile)
+		} else {
+			log.Println("WARNING: watcher \"cursorFile\" is not configured, the watcher will start over after a restart")
+		}
+
+		w := watcher.New(pool, cursor, watcher.NewHistoryRecorder(historyStore), watcher.Config{
+			Client:          client,
+			ContractName:    conf.LiveCheck.ContractName,
+			UpdateFunctions: conf.LiveCheck.UpdateFunctions,
+			Measurements:    measurements,
+			SgxPolicy:       &conf.SgxPolicy,
+			PollInterval:    time.Duration(conf.Watcher.PollIntervalSeconds) * time.Second,
+			StartHeight:     conf.Watcher.StartHeight,
+		})
+
+		go w.Run(context.Background())
+	}
+
+	mux := api.CreateApi(pool, conf, client, measurements, refresher, historyStore)
 
 	bindAddr := fmt.Sprintf(":%d", conf.Port)
 
 	server := &http.Server{
 		IdleTimeout:       time.Second * IdleTimeout,
-		ReadHeaderTimeout: time.Second * ReadWriteTimeout,
-		WriteTimeout:      time.Second * ReadWriteTimeout,
+		ReadHeaderTimeout: time.Second * ReadHeaderTimeout,
+		WriteTimeout:      conf.WriteTimeout(),
 		Addr:              bindAddr,
 		Handler:           mux,
 	}
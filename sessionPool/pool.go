@@ -0,0 +1,174 @@
+package sessionPool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+var (
+	ErrPoolClosed  = errors.New("session pool is closed")
+	ErrInvalidSize = errors.New("session pool size must be positive")
+)
+
+// a fixed message that is hashed to check that a returned session still works
+var healthCheckMessage = []byte("oracle-verification-backend")
+
+// Pool keeps a fixed number of reusable Aleo wrapper sessions. Creating a session instantiates a new WASM module,
+// which is expensive, so the handlers borrow sessions from the pool instead.
+//
+// A session is not goroutine-safe, a borrowed session belongs to the borrower until it's returned with Put.
+type Pool struct {
+	wrapper aleo_wrapper.Wrapper
+	size    int
+
+	// every slot is either a ready session or nil, which means that the slot needs a new session
+	slots chan aleo_wrapper.Session
+
+	// the expected health check hash, computed with the first session
+	healthCheckHash []byte
+
+	closed chan struct{}
+}
+
+// NewPool creates a pool of size sessions. All sessions are created immediately.
+func NewPool(wrapper aleo_wrapper.Wrapper, size int) (*Pool, error) {
+	if size <= 0 {
+		return nil, ErrInvalidSize
+	}
+
+	p := &Pool{
+		wrapper: wrapper,
+		size:    size,
+		slots:   make(chan aleo_wrapper.Session, size),
+		closed:  make(chan struct{}),
+	}
+
+	for i := 0; i < size; i++ {
+		session, err := wrapper.NewSession()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		if p.healthCheckHash == nil {
+			p.healthCheckHash, err = session.HashMessage(healthCheckMessage)
+			if err != nil {
+				session.Close()
+				p.Close()
+				return nil, err
+			}
+		}
+
+		p.slots <- session
+	}
+
+	return p, nil
+}
+
+// Size returns the maximum number of sessions in the pool
+func (p *Pool) Size() int {
+	return p.size
+}
+
+func (p *Pool) fill(session aleo_wrapper.Session) (aleo_wrapper.Session, error) {
+	if session != nil {
+		return session, nil
+	}
+
+	session, err := p.wrapper.NewSession()
+	if err != nil {
+		// give the slot back so that the next borrower can try again
+		p.slots <- nil
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Get borrows a session from the pool, waiting until one is available or the context is done.
+// The session must be returned with Put.
+func (p *Pool) Get(ctx context.Context) (aleo_wrapper.Session, error) {
+	select {
+	case <-p.closed:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	select {
+	case session := <-p.slots:
+		return p.fill(session)
+	case <-p.closed:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) isHealthy(session aleo_wrapper.Session) bool {
+	hash, err := session.HashMessage(healthCheckMessage)
+	if err != nil {
+		log.Println("sessionPool: session failed health check:", err)
+		return false
+	}
+
+	if !bytes.Equal(hash, p.healthCheckHash) {
+		log.Println("sessionPool: session failed health check: unexpected hash")
+		return false
+	}
+
+	return true
+}
+
+// Put returns a borrowed session to the pool. The session is health-checked, a broken session is closed and
+// replaced with a new one.
+func (p *Pool) Put(session aleo_wrapper.Session) {
+	if session == nil {
+		return
+	}
+
+	select {
+	case <-p.closed:
+		session.Close()
+		return
+	default:
+	}
+
+	if !p.isHealthy(session) {
+		session.Close()
+
+		var err error
+		session, err = p.wrapper.NewSession()
+		if err != nil {
+			log.Println("sessionPool: failed to replace a broken session:", err)
+			// the slot will be filled on the next Get
+			session = nil
+		}
+	}
+
+	p.slots <- session
+}
+
+// Close closes all sessions that are currently in the pool. Sessions that are returned after closing are closed by Put.
+func (p *Pool) Close() {
+	select {
+	case <-p.closed:
+		return
+	default:
+		close(p.closed)
+	}
+
+	for {
+		select {
+		case session := <-p.slots:
+			if session != nil {
+				session.Close()
+			}
+		default:
+			return
+		}
+	}
+}
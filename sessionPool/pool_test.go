@@ -0,0 +1,149 @@
+package sessionPool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+)
+
+func TestNewPool(t *testing.T) {
+	wrapper := &testutil.FakeWrapper{}
+
+	if _, err := NewPool(wrapper, 0); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("NewPool() with size 0 error = %v, want %v", err, ErrInvalidSize)
+	}
+
+	pool, err := NewPool(wrapper, 3)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	defer pool.Close()
+
+	if wrapper.Created != 3 {
+		t.Errorf("NewPool() created %d sessions, want 3", wrapper.Created)
+	}
+}
+
+func TestPool_GetPut(t *testing.T) {
+	wrapper := &testutil.FakeWrapper{}
+
+	pool, err := NewPool(wrapper, 1)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	defer pool.Close()
+
+	session, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// the only session is borrowed
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if other, err := pool.Get(canceled); !errors.Is(err, context.Canceled) || other != nil {
+		t.Fatalf("Get() with a canceled context = %v, %v, want %v", other, err, context.Canceled)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() on exhausted pool error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	pool.Put(session)
+
+	reused, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reused != session {
+		t.Errorf("Get() returned a new session, want the returned one to be reused")
+	}
+	if wrapper.Created != 1 {
+		t.Errorf("wrapper created %d sessions, want 1", wrapper.Created)
+	}
+}
+
+func TestPool_PutReplacesBrokenSession(t *testing.T) {
+	wrapper := &testutil.FakeWrapper{}
+
+	pool, err := NewPool(wrapper, 1)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	defer pool.Close()
+
+	session, _ := pool.Get(context.Background())
+	session.(*testutil.FakeSession).Broken = true
+	pool.Put(session)
+
+	if !session.(*testutil.FakeSession).Closed {
+		t.Errorf("Put() didn't close a broken session")
+	}
+
+	replacement, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if replacement == session {
+		t.Errorf("Get() returned a broken session")
+	}
+	if wrapper.Created != 2 {
+		t.Errorf("wrapper created %d sessions, want 2", wrapper.Created)
+	}
+}
+
+func TestPool_RefillsFailedReplacement(t *testing.T) {
+	wrapper := &testutil.FakeWrapper{}
+
+	pool, err := NewPool(wrapper, 1)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	defer pool.Close()
+
+	session, _ := pool.Get(context.Background())
+	session.(*testutil.FakeSession).Broken = true
+
+	// the replacement cannot be created right away
+	wrapper.Fail = true
+	pool.Put(session)
+
+	if _, err := pool.Get(context.Background()); err == nil {
+		t.Fatalf("Get() expected to fail while the wrapper cannot create sessions")
+	}
+
+	wrapper.Fail = false
+
+	replacement, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if replacement == nil || replacement == session {
+		t.Errorf("Get() didn't create a replacement session")
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	pool, err := NewPool(&testutil.FakeWrapper{}, 2)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+
+	session, _ := pool.Get(context.Background())
+
+	pool.Close()
+
+	if _, err := pool.Get(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Get() after Close() error = %v, want %v", err, ErrPoolClosed)
+	}
+
+	pool.Put(session)
+	if !session.(*testutil.FakeSession).Closed {
+		t.Errorf("Put() after Close() didn't close the session")
+	}
+}
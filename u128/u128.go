@@ -3,6 +3,8 @@ package u128
 import (
 	"errors"
 	"math/big"
+	"slices"
+	"strings"
 )
 
 func SliceToU128(buf []byte) (*big.Int, error) {
@@ -20,3 +22,21 @@ func SliceToU128(buf []byte) (*big.Int, error) {
 
 	return result, nil
 }
+
+// ParseU128 parses a decimal u128 value, with or without the Leo "u128" suffix, into 16 little-endian bytes
+func ParseU128(literal string) ([]byte, error) {
+	value, ok := new(big.Int).SetString(strings.TrimSuffix(literal, "u128"), 10)
+	if !ok {
+		return nil, errors.New("cannot parse u128: invalid number")
+	}
+
+	if value.Sign() < 0 || value.BitLen() > 128 {
+		return nil, errors.New("cannot parse u128: out of range")
+	}
+
+	buf := make([]byte, 16)
+	value.FillBytes(buf)
+	slices.Reverse(buf)
+
+	return buf, nil
+}
@@ -1,6 +1,7 @@
 package u128
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -67,3 +68,61 @@ func Test_sliceToU128(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseU128(t *testing.T) {
+	tests := []struct {
+		name    string
+		literal string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			literal: "",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			literal: "abc",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			literal: "-1u128",
+			wantErr: true,
+		},
+		{
+			name:    "too big",
+			literal: "340282366920938463463374607431768211456",
+			wantErr: true,
+		},
+		{
+			name:    "valid",
+			literal: "129127208515966861317",
+			want:    []byte{5, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:    "valid with suffix",
+			literal: "340282366920938463463374607431768211455u128",
+			want:    []byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseU128(tt.literal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseU128() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ParseU128() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
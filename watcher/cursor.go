@@ -0,0 +1,119 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CursorStore keeps the height of the last processed block so that the watcher can resume after a restart.
+// Implementations must be goroutine-safe.
+type CursorStore interface {
+	// Load returns the height of the last processed block, ok is false if no block was processed yet
+	Load() (height uint64, ok bool, err error)
+	// Save stores the height of the last processed block
+	Save(height uint64) error
+}
+
+// MemoryCursorStore is an in-memory CursorStore, the watcher starts over after a restart.
+type MemoryCursorStore struct {
+	mu     sync.Mutex
+	height uint64
+	ok     bool
+}
+
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return new(MemoryCursorStore)
+}
+
+func (s *MemoryCursorStore) Load() (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.height, s.ok, nil
+}
+
+func (s *MemoryCursorStore) Save(height uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.height = height
+	s.ok = true
+
+	return nil
+}
+
+type fileCursor struct {
+	Height uint64 `json:"height"`
+}
+
+// FileCursorStore keeps the cursor in a JSON file. The file is written to disk and then replaced atomically on every save,
+// so a crash cannot leave a partially written cursor. An empty or corrupted file is treated as a missing cursor.
+type FileCursorStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{
+		path: path,
+	}
+}
+
+func (s *FileCursorStore) Load() (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	// a cursor that cannot be read is discarded, otherwise every poll would fail until the file is fixed
+	cursor := new(fileCursor)
+	if err = json.Unmarshal(content, cursor); err != nil {
+		log.Printf("watcher: ignoring the corrupted cursor in %s: %v\n", s.path, err)
+		return 0, false, nil
+	}
+
+	return cursor.Height, true, nil
+}
+
+func (s *FileCursorStore) Save(height uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := json.Marshal(&fileCursor{Height: height})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// without syncing, a crash after the rename can leave an empty file
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
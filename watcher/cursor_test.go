@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCursorStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor.json")
+	store := NewFileCursorStore(path)
+
+	if _, ok, err := store.Load(); ok || err != nil {
+		t.Fatalf("Load() of a missing file = %v, %v, want an empty cursor", ok, err)
+	}
+
+	for _, height := range []uint64{10, 11} {
+		if err := store.Save(height); err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok, err := NewFileCursorStore(path).Load()
+		if err != nil || !ok || got != height {
+			t.Errorf("Load() = %d, %v, %v, want %d", got, ok, err, height)
+		}
+	}
+
+	// only the cursor file is left after saving
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the cursor file, got %d files", len(entries))
+	}
+
+	// an empty or corrupted cursor is treated as a missing one
+	for _, content := range []string{"", "not json", `{"height": 1`} {
+		if err = os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := store.Load(); ok || err != nil {
+			t.Errorf("Load() of %q = %v, %v, want an empty cursor", content, ok, err)
+		}
+	}
+
+	// the next save replaces the corrupted cursor
+	if err = store.Save(12); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := store.Load(); err != nil || !ok || got != 12 {
+		t.Errorf("Load() = %d, %v, %v, want 12", got, ok, err)
+	}
+}
@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/history"
+)
+
+// Verification verdict for an oracle update transition. A transaction with an update transition that has unexpected
+// inputs has a single result without the transition
+type Result struct {
+	Height        uint64 `json:"height"`
+	TransactionID string `json:"transactionId"`
+	TransitionID  string `json:"transitionId,omitempty"`
+	Function      string `json:"function,omitempty"`
+	ReportType    string `json:"reportType,omitempty"`
+	Valid         bool   `json:"valid"`
+	Error         string `json:"error,omitempty"`
+	// hex-encoded SHA-256 hash of the report bytes, empty if the report input cannot be read
+	ReportHash string `json:"reportHash,omitempty"`
+	// label and source of the measurement set that the report matched
+	MatchedMeasurements string `json:"matchedMeasurements,omitempty"`
+	MeasurementsSource  string `json:"measurementsSource,omitempty"`
+	// URL and timestamp of the attested request of a valid update
+	Url                  string `json:"url,omitempty"`
+	AttestationTimestamp int64  `json:"attestationTimestamp,omitempty"`
+	// time of the block with the update, the update is verified as of this time
+	BlockTime  time.Time `json:"blockTime"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// Recorder keeps the verification results of the watcher. Implementations must be goroutine-safe.
+type Recorder interface {
+	Record(result *Result) error
+}
+
+// HistoryRecorder adds the results to the verification history
+type HistoryRecorder struct {
+	store history.Store
+}
+
+func NewHistoryRecorder(store history.Store) *HistoryRecorder {
+	return &HistoryRecorder{store: store}
+}
+
+func (r *HistoryRecorder) Record(result *Result) error {
+	return r.store.Add(&history.Record{
+		Source:               history.SOURCE_WATCHER,
+		TransactionID:        result.TransactionID,
+		TransitionID:         result.TransitionID,
+		ReportType:           result.ReportType,
+		ReportHash:           result.ReportHash,
+		MatchedMeasurements:  result.MatchedMeasurements,
+		MeasurementsSource:   result.MeasurementsSource,
+		Url:                  result.Url,
+		AttestationTimestamp: result.AttestationTimestamp,
+		ReferenceTime:        result.BlockTime,
+		Valid:                result.Valid,
+		ErrorMessage:         result.Error,
+	})
+}
@@ -0,0 +1,235 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+// default time between checks for new blocks
+const DefaultPollInterval = 10 * time.Second
+
+type Config struct {
+	Client       *contract.Client
+	ContractName string
+	// names of the contract's oracle update transitions
+	UpdateFunctions contract.UpdateFunctions
+	Measurements    *attestation.MeasurementRegistry
+	SgxPolicy       *sgx.Policy
+	// time between checks for new blocks, DefaultPollInterval if zero
+	PollInterval time.Duration
+	// first block to process if the cursor is empty. If zero, the watcher starts at the latest block
+	StartHeight uint64
+}
+
+// Watcher follows new blocks on the Aleo network and verifies every oracle update of the contract.
+// A block is marked as processed only after all of its results are recorded, so after a failure
+// the block is processed again and some results can be recorded twice.
+type Watcher struct {
+	pool     *sessionPool.Pool
+	cursor   CursorStore
+	recorder Recorder
+	conf     Config
+
+	// verifies an oracle update transition, replaced in tests
+	verify func(aleoSession aleo_wrapper.Session, transition *contract.OracleTransition, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*contract.VerifiedTransaction, error)
+}
+
+func New(pool *sessionPool.Pool, cursor CursorStore, recorder Recorder, conf Config) *Watcher {
+	if conf.PollInterval == 0 {
+		conf.PollInterval = DefaultPollInterval
+	}
+
+	return &Watcher{
+		pool:     pool,
+		cursor:   cursor,
+		recorder: recorder,
+		conf:     conf,
+		verify:   contract.VerifyOracleTransition,
+	}
+}
+
+// Run processes new blocks until the context is canceled
+func (w *Watcher) Run(ctx context.Context) {
+	log.Printf("watcher: following %s updates using %s\n", w.conf.ContractName, strings.Join(w.conf.Client.Endpoints(), ", "))
+
+	ticker := time.NewTicker(w.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Poll(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Println("watcher: failed to process new blocks:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("watcher: stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll processes all blocks after the cursor up to the latest block
+func (w *Watcher) Poll(ctx context.Context) error {
+	latest, err := w.conf.Client.GetLatestHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get latest block height: %w", err)
+	}
+
+	last, ok, err := w.cursor.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	next := last + 1
+	if !ok {
+		next = latest
+		if w.conf.StartHeight != 0 {
+			next = w.conf.StartHeight
+		}
+	}
+
+	for height := next; height <= latest; height++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = w.processBlock(ctx, height); err != nil {
+			return fmt.Errorf("block %d: %w", height, err)
+		}
+
+		if err = w.cursor.Save(height); err != nil {
+			return fmt.Errorf("failed to save cursor at block %d: %w", height, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) processBlock(ctx context.Context, height uint64) error {
+	block, err := w.conf.Client.GetBlock(ctx, height)
+	if err != nil {
+		return err
+	}
+
+	for _, confirmed := range block.Transactions {
+		// rejected transactions don't change the contract state
+		if confirmed.Status != contract.TRANSACTION_STATUS_ACCEPTED {
+			continue
+		}
+
+		transitions, err := contract.FindOracleTransitions(&confirmed.Transaction, w.conf.ContractName, w.conf.UpdateFunctions)
+		if errors.Is(err, contract.ErrNoOracleTransition) {
+			continue
+		}
+
+		results := make([]*Result, 0, len(transitions))
+
+		// an update transition has unexpected inputs, the transaction fails without verifying the updates
+		if err != nil {
+			results = append(results, &Result{
+				Height:        height,
+				TransactionID: confirmed.Transaction.ID,
+				Error:         err.Error(),
+				BlockTime:     block.Time(),
+				VerifiedAt:    time.Now().UTC(),
+			})
+		}
+
+		for _, transition := range transitions {
+			result := &Result{
+				Height:        height,
+				TransactionID: transition.TransactionID,
+				TransitionID:  transition.TransitionID,
+				Function:      transition.Function,
+				ReportType:    transition.ReportType,
+				BlockTime:     block.Time(),
+			}
+
+			if err = w.verifyTransition(ctx, transition, block.Time(), result); err != nil {
+				// the context is canceled while waiting for a session, the update is verified after a restart
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return err
+				}
+
+				result.Error = err.Error()
+			}
+
+			result.VerifiedAt = time.Now().UTC()
+
+			if result.Valid {
+				log.Printf("watcher: block %d: %s update %s in %s is valid\n", height, result.ReportType, result.TransitionID, result.TransactionID)
+			} else {
+				log.Printf("watcher: block %d: %s update %s in %s is INVALID: %s\n", height, result.ReportType, result.TransitionID, result.TransactionID, result.Error)
+			}
+
+			results = append(results, result)
+		}
+
+		// the transaction passes only if all of its updates pass
+		transactionValid := true
+		for _, result := range results {
+			transactionValid = transactionValid && result.Valid
+		}
+
+		if !transactionValid {
+			log.Printf("watcher: block %d: transaction %s is INVALID\n", height, confirmed.Transaction.ID)
+		}
+
+		for _, result := range results {
+			if err = w.recorder.Record(result); err != nil {
+				return fmt.Errorf("failed to record result of %s: %w", result.TransactionID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifies the update as of the block time, so that measurement sets are matched by their validity at the time of the update
+func (w *Watcher) verifyTransition(ctx context.Context, transition *contract.OracleTransition, blockTime time.Time, result *Result) (err error) {
+	// a malformed update must not stop the watcher
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while verifying update: %v", r)
+		}
+	}()
+
+	aleoSession, err := w.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer w.pool.Put(aleoSession)
+
+	// the hash identifies the report in the history even if it fails verification
+	if report, err := transition.RecoverReport(); err == nil {
+		result.ReportHash = history.HashReport(report)
+	}
+
+	verified, err := w.verify(aleoSession, transition, w.conf.Measurements.Sets(), w.conf.SgxPolicy, blockTime)
+	if err != nil {
+		return err
+	}
+
+	result.Valid = true
+	result.Url = verified.DecodedData.Url
+	result.AttestationTimestamp = verified.DecodedData.Timestamp
+	if verified.Report.Measurements != nil {
+		result.MatchedMeasurements = verified.Report.Measurements.Label
+		result.MeasurementsSource = verified.Report.Measurements.Source
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zkportal/oracle-verification-backend/attestation"
+	"github.com/zkportal/oracle-verification-backend/attestation/sgx"
+	"github.com/zkportal/oracle-verification-backend/contract"
+	"github.com/zkportal/oracle-verification-backend/history"
+	"github.com/zkportal/oracle-verification-backend/internal/testutil"
+	"github.com/zkportal/oracle-verification-backend/sessionPool"
+
+	aleo_wrapper "github.com/zkportal/aleo-utils-go"
+)
+
+const testContractName = "official_oracle.aleo"
+
+// fakeNode serves blocks like the Aleo node API
+type fakeNode struct {
+	mu     sync.Mutex
+	blocks map[uint64]*contract.Block
+	latest uint64
+	// heights that respond with an error
+	failing map[uint64]bool
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, string) {
+	node := &fakeNode{
+		blocks:  make(map[uint64]*contract.Block),
+		failing: make(map[uint64]bool),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		node.mu.Lock()
+		defer node.mu.Unlock()
+
+		path := strings.TrimPrefix(req.URL.Path, "/testnet")
+		if path == "/block/height/latest" {
+			json.NewEncoder(w).Encode(node.latest)
+			return
+		}
+
+		height, err := strconv.ParseUint(strings.TrimPrefix(path, "/block/"), 10, 64)
+		if err != nil || node.failing[height] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		block, ok := node.blocks[height]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(block)
+	}))
+	t.Cleanup(server.Close)
+
+	return node, server.URL + "/testnet"
+}
+
+// adds a block with the transactions on top of the chain
+func (n *fakeNode) addBlock(transactions ...contract.ConfirmedTransaction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.latest++
+
+	block := &contract.Block{Transactions: transactions}
+	block.Header.Metadata.Height = n.latest
+	block.Header.Metadata.Timestamp = 1701851063 + int64(n.latest)
+
+	n.blocks[n.latest] = block
+}
+
+func (n *fakeNode) setFailing(height uint64, failing bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.failing[height] = failing
+}
+
+// a whole number of u128 values, so that the report in a transition input has no padding
+var testReport = []byte("report, 16 bytes")
+
+// creates a transaction with a transition of the program for every function
+func transaction(id, status, program string, functions ...string) contract.ConfirmedTransaction {
+	tx := contract.ConfirmedTransaction{
+		Status: status,
+		Type:   "execute",
+		Transaction: contract.Transaction{
+			Type: "execute",
+			ID:   id,
+		},
+	}
+
+	tx.Transaction.Execution = &struct {
+		Transitions []contract.Transition `json:"transitions"`
+	}{}
+
+	for idx, function := range functions {
+		tx.Transaction.Execution.Transitions = append(tx.Transaction.Execution.Transitions, contract.Transition{
+			ID:       "au" + strconv.Itoa(idx+1) + id,
+			Program:  program,
+			Function: function,
+			Inputs: []contract.TransitionInput{
+				{Type: "public", Value: testutil.LeoMessage([]byte("report data"))},
+				{Type: "public", Value: testutil.LeoMessage(testReport)},
+			},
+		})
+	}
+
+	return tx
+}
+
+func newTestWatcher(t *testing.T, apiBaseUrl string, cursor CursorStore, startHeight uint64) (*Watcher, history.Store) {
+	pool, err := sessionPool.NewPool(&testutil.FakeWrapper{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	client, err := contract.NewClient(contract.ClientConfig{
+		Endpoints:   []string{apiBaseUrl},
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := history.NewMemoryStore(history.Retention{})
+
+	w := New(pool, cursor, NewHistoryRecorder(store), Config{
+		Client:       client,
+		ContractName: testContractName,
+		StartHeight:  startHeight,
+	})
+
+	// SGX updates pass, the rest go through the real verification, which fails for fake reports
+	w.verify = func(aleoSession aleo_wrapper.Session, transition *contract.OracleTransition, measurements []attestation.MeasurementSet, sgxPolicy *sgx.Policy, verificationTime time.Time) (*contract.VerifiedTransaction, error) {
+		if transition.ReportType == attestation.TEE_TYPE_SGX {
+			return &contract.VerifiedTransaction{
+				Transition: transition,
+				VerifiedOracleUpdate: &attestation.VerifiedOracleUpdate{
+					Report: &attestation.VerifiedReport{Measurements: &attestation.MeasurementSet{Label: "target"}},
+					DecodedData: &attestation.DecodedProofData{
+						AttestationRequest: attestation.AttestationRequest{Url: "example.com"},
+						Timestamp:          1701851063,
+					},
+				},
+			}, nil
+		}
+
+		return contract.VerifyOracleTransition(aleoSession, transition, measurements, sgxPolicy, verificationTime)
+	}
+
+	return w, store
+}
+
+// returns the recorded results, oldest first
+func recordedResults(t *testing.T, store history.Store) []history.Record {
+	t.Helper()
+
+	records, err := store.Query(&history.Filter{Limit: history.MaxQueryLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Reverse(records)
+
+	return records
+}
+
+func assertCursor(t *testing.T, cursor CursorStore, want uint64) {
+	t.Helper()
+
+	height, ok, err := cursor.Load()
+	if err != nil || !ok || height != want {
+		t.Fatalf("cursor = %d, %v, %v, want %d", height, ok, err, want)
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	node, apiBaseUrl := newFakeNode(t)
+
+	node.addBlock(transaction("at1transfer", contract.TRANSACTION_STATUS_ACCEPTED, "credits.aleo", "transfer_public"))
+	node.addBlock(
+		transaction("at1sgx", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"),
+		transaction("at1rejected", "rejected", testContractName, "set_data_sgx"),
+	)
+	node.addBlock(transaction("at1nitro", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_nitro"))
+
+	cursor := NewMemoryCursorStore()
+	w, store := newTestWatcher(t, apiBaseUrl, cursor, 1)
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCursor(t, cursor, 3)
+
+	results := recordedResults(t, store)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+
+	// updates are verified as of the time of their block
+	if r := results[0]; r.Source != history.SOURCE_WATCHER || r.TransactionID != "at1sgx" || r.TransitionID != "au1at1sgx" || r.ReferenceTime.Unix() != 1701851065 ||
+		!r.Valid || r.ReportType != attestation.TEE_TYPE_SGX || r.MatchedMeasurements != "target" || r.Url != "example.com" {
+		t.Errorf("unexpected result for the SGX update: %+v", r)
+	}
+
+	if r := results[1]; r.TransactionID != "at1nitro" || r.ReferenceTime.Unix() != 1701851066 || r.Valid || r.ErrorMessage == "" ||
+		r.ReportType != attestation.TEE_TYPE_NITRO || r.ReportHash != history.HashReport(testReport) {
+		t.Errorf("unexpected result for the Nitro update: %+v", r)
+	}
+
+	// only new blocks are processed on the next poll
+	node.addBlock(transaction("at1sgx2", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCursor(t, cursor, 4)
+
+	results = recordedResults(t, store)
+	if len(results) != 3 || results[2].TransactionID != "at1sgx2" {
+		t.Errorf("unexpected results after the second poll: %+v", results)
+	}
+}
+
+func TestWatcher_MultipleUpdates(t *testing.T) {
+	node, apiBaseUrl := newFakeNode(t)
+
+	node.addBlock(
+		transaction("at1valid", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx", "set_data_sgx"),
+		transaction("at1mixed", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx", "get_data", "set_data_nitro"),
+	)
+
+	w, store := newTestWatcher(t, apiBaseUrl, NewMemoryCursorStore(), 1)
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := recordedResults(t, store)
+
+	want := []struct {
+		transitionId string
+		valid        bool
+	}{
+		{transitionId: "au1at1valid", valid: true},
+		{transitionId: "au2at1valid", valid: true},
+		// the transition that is not an update is skipped
+		{transitionId: "au1at1mixed", valid: true},
+		{transitionId: "au3at1mixed", valid: false},
+	}
+
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+
+	for idx, r := range results {
+		if r.TransitionID != want[idx].transitionId || r.Valid != want[idx].valid {
+			t.Errorf("result %d = %+v, want %+v", idx, r, want[idx])
+		}
+	}
+}
+
+func TestWatcher_StartsAtLatestBlock(t *testing.T) {
+	node, apiBaseUrl := newFakeNode(t)
+
+	node.addBlock(transaction("at1old", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+	node.addBlock(transaction("at1latest", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+
+	cursor := NewMemoryCursorStore()
+	w, store := newTestWatcher(t, apiBaseUrl, cursor, 0)
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCursor(t, cursor, 2)
+
+	results := recordedResults(t, store)
+	if len(results) != 1 || results[0].TransactionID != "at1latest" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestWatcher_ResumesAfterFailure(t *testing.T) {
+	node, apiBaseUrl := newFakeNode(t)
+
+	node.addBlock(transaction("at1first", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+	node.addBlock(transaction("at1second", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+	node.addBlock(transaction("at1third", contract.TRANSACTION_STATUS_ACCEPTED, testContractName, "set_data_sgx"))
+
+	node.setFailing(2, true)
+
+	cursor := NewFileCursorStore(filepath.Join(t.TempDir(), "cursor.json"))
+	w, store := newTestWatcher(t, apiBaseUrl, cursor, 1)
+
+	err := w.Poll(context.Background())
+	if !errors.Is(err, contract.ErrNodeApi) {
+		t.Fatalf("Poll() error = %v, want %v", err, contract.ErrNodeApi)
+	}
+
+	assertCursor(t, cursor, 1)
+
+	if results := recordedResults(t, store); len(results) != 1 {
+		t.Fatalf("got %d results before the failure, want 1", len(results))
+	}
+
+	node.setFailing(2, false)
+
+	// a new watcher with the same cursor continues after the last processed block
+	w, store = newTestWatcher(t, apiBaseUrl, cursor, 1)
+	if err = w.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCursor(t, cursor, 3)
+
+	results := recordedResults(t, store)
+	if len(results) != 2 || results[0].TransactionID != "at1second" || results[1].TransactionID != "at1third" {
+		t.Errorf("unexpected results after resuming: %+v", results)
+	}
+}
+
+func TestWatcher_CanceledContext(t *testing.T) {
+	node, apiBaseUrl := newFakeNode(t)
+	node.addBlock()
+
+	cursor := NewMemoryCursorStore()
+	w, _ := newTestWatcher(t, apiBaseUrl, cursor, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := w.Poll(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Poll() error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, ok, _ := cursor.Load(); ok {
+		t.Error("cursor moved with a canceled context")
+	}
+}